@@ -1,23 +1,40 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"net"
 	"os"
+	"slices"
+	"strings"
 
 	utils "github.com/aorith/whoip/internal"
+	"github.com/aorith/whoip/pkg/sources"
 	"github.com/aorith/whoip/pkg/whoip"
 )
 
 var (
 	showVersion    bool
 	showCategories bool
+	inputFile      string
+	outputFormat   string
+	sourceOpts     sourceOptions
+	filter         whoip.Filter
 )
 
 func main() {
 	flag.BoolVar(&showVersion, "version", false, "show version information and exit")
 	flag.BoolVar(&showCategories, "categories", false, "show available categories and exit")
+	flag.StringVar(&inputFile, "f", "", "read IP addresses from `file`, one per line ('-' for stdin)")
+	flag.StringVar(&outputFormat, "o", string(whoip.FormatJSON), fmt.Sprintf("output `format`, one of: %s", joinFormats()))
+	addFilterFlags(flag.CommandLine, &filter)
+	addSourceFlags(flag.CommandLine, &sourceOpts)
+
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 
 	if showVersion {
@@ -25,23 +42,130 @@ func main() {
 		os.Exit(0)
 	}
 
+	sourceOpts.apply()
+
 	if showCategories {
-		fmt.Printf("%s\n", whoip.Categories())
+		if err := whoip.WriteJSON(os.Stdout, whoip.Categories()); err != nil {
+			fmt.Printf("Error writing categories: %v\n", err)
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
 
+	format := whoip.Format(outputFormat)
+	if !slices.Contains(whoip.Formats, format) {
+		fmt.Printf("Invalid output format: %s (valid formats: %s)\n", outputFormat, joinFormats())
+		os.Exit(1)
+	}
+
+	if err := filter.Validate(); err != nil {
+		fmt.Printf("Invalid filter: %v\n", err)
+		os.Exit(1)
+	}
+
 	args := flag.Args()
-	if len(args) < 1 {
-		fmt.Println("Usage: whoip-cli [IP Address]")
+	if len(args) < 1 && inputFile == "" {
+		fmt.Println("Usage: whoip-cli [-f file] [IP Address|CIDR|Range...]")
+		fmt.Println("Use '-' as IP Address or file to read the addresses from stdin.")
+		fmt.Println("       whoip-cli export [-format format] [flags]")
 		os.Exit(1)
 	}
 
-	ipStr := args[0]
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		fmt.Printf("Invalid IP address: %s\n", ipStr)
+	// Failures are logged per source, lookups use whatever data is available.
+	_ = whoip.UpdateSources(context.Background(), filter)
+
+	results := []whoip.Result{}
+	invalid := false
+	err := readInputs(args, inputFile, func(ipStr string) {
+		result := whoip.MatchString(ipStr, filter)
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "Invalid query: %s\n", result.Error)
+			invalid = true
+		}
+		results = append(results, result)
+	})
+	if err != nil {
+		fmt.Printf("Error reading input: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := whoip.WriteResults(os.Stdout, format, results); err != nil {
+		fmt.Printf("Error writing results: %v\n", err)
+		os.Exit(1)
+	}
+
+	if invalid {
 		os.Exit(1)
 	}
+}
 
-	fmt.Printf("%s\n", whoip.FindIP(ip))
+// joinFormats returns the supported output formats separated by commas.
+func joinFormats() string {
+	formats := make([]string, len(whoip.Formats))
+	for i, f := range whoip.Formats {
+		formats[i] = string(f)
+	}
+	return strings.Join(formats, ", ")
+}
+
+// addFilterFlags adds the flags to select sources and categories to the flag set.
+func addFilterFlags(fs *flag.FlagSet, filter *whoip.Filter) {
+	fs.Func("category", "only use sources of these comma separated `categories`", listFlag(&filter.Categories))
+	fs.Func("source", "only use these comma separated `sources`", listFlag(&filter.Sources))
+	fs.Func("exclude-category", "skip sources of these comma separated `categories`", listFlag(&filter.ExcludeCategories))
+	fs.Func("exclude-source", "skip these comma separated `sources`", listFlag(&filter.ExcludeSources))
+}
+
+// sourceOptions holds the flags that configure the sources.
+type sourceOptions struct {
+	configFile string
+	dataDir    string
+	inMemory   bool
+	http       sources.HTTPOptions
+}
+
+// addSourceFlags adds the flags that configure the sources to the flag set.
+func addSourceFlags(fs *flag.FlagSet, opts *sourceOptions) {
+	fs.StringVar(&opts.configFile, "config", os.Getenv("WHOIP_CONFIG"), "load extra sources from a YAML, JSON or TOML config `file` (env WHOIP_CONFIG)")
+	fs.StringVar(&opts.dataDir, "data-dir", "", "save the data of the sources in `directory` (env WHOIP_DATA_DIR)")
+	fs.BoolVar(&opts.inMemory, "in-memory", false, "never read or save the data of the sources from disk")
+	fs.DurationVar(&sources.DefaultMaxStaleAge, "max-stale-age", sources.DefaultMaxStaleAge, "keep using expired data of the sources that fail to refresh for up to `duration`")
+
+	opts.http = sources.DefaultHTTPOptions()
+	fs.DurationVar(&opts.http.Timeout, "timeout", opts.http.Timeout, "timeout of every download attempt")
+	fs.IntVar(&opts.http.Retries, "retries", opts.http.Retries, "retries of downloads that fail with network errors or 5xx responses")
+	fs.StringVar(&opts.http.Proxy, "proxy", "", "download through the proxy at `url` (default from HTTPS_PROXY)")
+	fs.StringVar(&opts.http.CAFile, "ca-file", "", "trust the CA certificates of the PEM `file` besides the system ones")
+	fs.StringVar(&opts.http.UserAgent, "user-agent", sources.DefaultUserAgent(), "User-Agent header of the downloads")
+}
+
+// apply sets the data directory and the HTTP options and registers the sources of the config file, exiting on errors.
+func (opts sourceOptions) apply() {
+	switch {
+	case opts.inMemory:
+		sources.SetDataDirectory("")
+	case opts.dataDir != "":
+		sources.SetDataDirectory(opts.dataDir)
+	}
+
+	if err := sources.SetHTTPOptions(opts.http); err != nil {
+		fmt.Printf("Invalid HTTP options: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.configFile == "" {
+		return
+	}
+	if err := sources.RegisterConfig(opts.configFile); err != nil {
+		fmt.Printf("Invalid config: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// listFlag returns a flag function that appends comma separated items to the list.
+func listFlag(list *[]string) func(string) error {
+	return func(s string) error {
+		*list = append(*list, whoip.ParseList(s)...)
+		return nil
+	}
 }
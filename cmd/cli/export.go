@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aorith/whoip/pkg/export"
+	"github.com/aorith/whoip/pkg/whoip"
+)
+
+// runExport implements the 'export' command, which writes the prefixes of the
+// selected sources in a format ready to be loaded by other tools.
+func runExport(args []string) {
+	var (
+		format     string
+		outputFile string
+		sourceOpts sourceOptions
+		filter     whoip.Filter
+		opts       export.Options
+	)
+
+	fs := flag.NewFlagSet("whoip-cli export", flag.ExitOnError)
+	fs.StringVar(&format, "format", string(export.FormatNftables), fmt.Sprintf("export `format`, one of: %s", joinExportFormats()))
+	fs.StringVar(&outputFile, "out", "", "write the export to `file` instead of stdout")
+	fs.StringVar(&opts.Name, "name", "", "name of the generated set or table (default \"whoip\")")
+	fs.StringVar(&opts.Chain, "chain", "", "iptables chain of the rules (default \"INPUT\")")
+	fs.StringVar(&opts.Target, "target", "", "iptables target of the rules (default \"DROP\")")
+	fs.StringVar(&opts.Value, "value", "", "`value` of each prefix in web-server exports: source, category or detail:<Key> (default \"source\")")
+	addFilterFlags(fs, &filter)
+	addSourceFlags(fs, &sourceOpts)
+	fs.Parse(args)
+
+	sourceOpts.apply()
+
+	if !slices.Contains(export.Formats, export.Format(format)) {
+		fmt.Printf("Invalid export format: %s (valid formats: %s)\n", format, joinExportFormats())
+		os.Exit(1)
+	}
+
+	if export.Format(format) == export.FormatMMDB && outputFile == "" {
+		fmt.Println("The mmdb format requires an output file, use -out.")
+		os.Exit(1)
+	}
+
+	if err := export.ValidateValue(opts.Value); err != nil {
+		fmt.Printf("Invalid export options: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := filter.Validate(); err != nil {
+		fmt.Printf("Invalid filter: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Failures are logged per source, the export uses whatever data is available.
+	_ = whoip.UpdateSources(context.Background(), filter)
+
+	entries := whoip.Entries(filter)
+	if len(entries) == 0 {
+		fmt.Println("No prefixes found for the selected sources.")
+		os.Exit(1)
+	}
+
+	if outputFile == "" {
+		if err := export.Write(os.Stdout, export.Format(format), entries, opts); err != nil {
+			fmt.Printf("Error writing export: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		fmt.Printf("Error creating output file: %v\n", err)
+		os.Exit(1)
+	}
+	err = export.Write(file, export.Format(format), entries, opts)
+	// A failed close may leave the file truncated, so it is an error too.
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("Error writing export: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// joinExportFormats returns the supported export formats separated by commas.
+func joinExportFormats() string {
+	formats := make([]string, len(export.Formats))
+	for i, f := range export.Formats {
+		formats[i] = string(f)
+	}
+	return strings.Join(formats, ", ")
+}
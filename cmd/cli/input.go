@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readInputs calls fn for every address given as positional argument or listed in
+// the input file. The argument or file '-' reads the addresses from stdin.
+func readInputs(args []string, inputFile string, fn func(string)) error {
+	for _, arg := range args {
+		if arg == "-" {
+			if err := readAddresses(os.Stdin, fn); err != nil {
+				return fmt.Errorf("failed to read stdin: %v", err)
+			}
+			continue
+		}
+		fn(arg)
+	}
+
+	if inputFile == "" {
+		return nil
+	}
+
+	if inputFile == "-" {
+		if err := readAddresses(os.Stdin, fn); err != nil {
+			return fmt.Errorf("failed to read stdin: %v", err)
+		}
+		return nil
+	}
+
+	file, err := os.Open(inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %v", err)
+	}
+	defer file.Close()
+
+	if err := readAddresses(file, fn); err != nil {
+		return fmt.Errorf("failed to read input file '%s': %v", inputFile, err)
+	}
+	return nil
+}
+
+// readAddresses reads one address per line, ignoring blank lines and comments starting with '#'.
+func readAddresses(r io.Reader, fn func(string)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fn(line)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadAddresses(t *testing.T) {
+	input := "# header comment\n1.2.3.4\n\n  2001:db8::1  \n10.0.0.1 # trailing comment\n   \n"
+
+	var got []string
+	if err := readAddresses(strings.NewReader(input), func(s string) { got = append(got, s) }); err != nil {
+		t.Fatalf("Failed to read addresses: %v", err)
+	}
+
+	expected := []string{"1.2.3.4", "2001:db8::1", "10.0.0.1"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong addresses. Expected output:\n%v\n\nGot:\n%v\n", expected, got)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+
+	"github.com/aorith/whoip/pkg/whoip"
+)
+
+// errorResponse is the body returned on failed requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// newHandler returns the HTTP handler with all the API routes.
+func newHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/ip/{ip}", handleIP)
+	mux.HandleFunc("GET /v1/network/{network...}", handleNetwork)
+	return mux
+}
+
+// handleIP returns the sources that contain the requested IP address.
+func handleIP(w http.ResponseWriter, r *http.Request) {
+	ipStr := r.PathValue("ip")
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{fmt.Sprintf("invalid IP address: %s", ipStr)})
+		return
+	}
+
+	filter := filterFromQuery(r.URL.Query())
+	if err := filter.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+
+	info := whoip.Match(addr, filter)
+	if len(info) == 0 {
+		writeNotFound(w, filter, fmt.Sprintf("no sources found for IP address: %s", addr))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleNetwork returns the sources with prefixes that overlap the requested
+// CIDR or 'a-b' address range.
+func handleNetwork(w http.ResponseWriter, r *http.Request) {
+	rng, err := whoip.ParseRange(r.PathValue("network"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+
+	filter := filterFromQuery(r.URL.Query())
+	if err := filter.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+
+	info := whoip.MatchRange(rng, filter)
+	if len(info) == 0 {
+		writeNotFound(w, filter, fmt.Sprintf("no sources found for network: %s", rng))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// writeNotFound writes a 404 response with the message, or a 503 response if a
+// source selected by the filter is still loading its data and may match later.
+func writeNotFound(w http.ResponseWriter, filter whoip.Filter, message string) {
+	if whoip.Pending(filter) {
+		w.Header().Set("Retry-After", "10")
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{"the data of the sources is still loading"})
+		return
+	}
+	writeJSON(w, http.StatusNotFound, errorResponse{message})
+}
+
+// filterFromQuery builds a lookup filter from the 'source', 'category', 'exclude_source'
+// and 'exclude_category' query parameters. Every parameter accepts comma separated
+// values and can be repeated.
+func filterFromQuery(query url.Values) whoip.Filter {
+	list := func(name string) []string {
+		var items []string
+		for _, value := range query[name] {
+			items = append(items, whoip.ParseList(value)...)
+		}
+		return items
+	}
+
+	return whoip.Filter{
+		Sources:           list("source"),
+		Categories:        list("category"),
+		ExcludeSources:    list("exclude_source"),
+		ExcludeCategories: list("exclude_category"),
+	}
+}
+
+// writeJSON writes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aorith/whoip/pkg/sources"
+)
+
+func TestHandleIPStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "office.txt")
+	if err := os.WriteFile(path, []byte("198.51.100.0/24\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	office, err := sources.SourceConfig{Key: "office", Path: path, Format: sources.FormatText}.IPSource()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := office.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh source: %v", err)
+	}
+	if err := sources.Register("office", office); err != nil {
+		t.Fatal(err)
+	}
+	defer sources.Unregister("office")
+
+	handler := newHandler()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/v1/ip/not-an-ip", http.StatusBadRequest},
+		{"/v1/ip/192.0.2.1?source=unknown", http.StatusBadRequest},
+		{"/v1/ip/192.0.2.1?category=unknown", http.StatusBadRequest},
+		{"/v1/ip/198.51.100.1?source=office", http.StatusOK},
+		{"/v1/ip/192.0.2.1?source=office", http.StatusNotFound},
+		{"/v1/ip/192.0.2.1?source=aws&category=datacenter", http.StatusServiceUnavailable},
+		{"/v1/network/192.0.2.0/33", http.StatusBadRequest},
+		{"/v1/network/192.0.2.9-192.0.2.1", http.StatusBadRequest},
+		{"/v1/network/192.0.2.0/24?source=office", http.StatusNotFound},
+		{"/v1/network/192.0.2.0/24?source=aws", http.StatusServiceUnavailable},
+		{"/v1/network/192.0.2.1-192.0.2.9", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("Wrong status code for '%s'. Expected: %d, Got: %d", tt.path, tt.status, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Wrong content type for '%s': %s", tt.path, ct)
+		}
+	}
+}
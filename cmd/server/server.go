@@ -1,20 +1,88 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	utils "github.com/aorith/whoip/internal"
+	"github.com/aorith/whoip/pkg/sources"
+	"github.com/aorith/whoip/pkg/whoip"
 )
 
-var showVersion bool
+var (
+	showVersion bool
+	listenAddr  string
+	configFile  string
+	dataDir     string
+	inMemory    bool
+	httpOpts    = sources.DefaultHTTPOptions()
+)
 
 func main() {
 	flag.BoolVar(&showVersion, "version", false, "show version information and exit")
+	flag.StringVar(&listenAddr, "addr", ":8080", "address to listen on")
+	flag.StringVar(&configFile, "config", os.Getenv("WHOIP_CONFIG"), "load extra sources from a YAML, JSON or TOML config `file` (env WHOIP_CONFIG)")
+	flag.StringVar(&dataDir, "data-dir", "", "save the data of the sources in `directory` (env WHOIP_DATA_DIR)")
+	flag.BoolVar(&inMemory, "in-memory", false, "never read or save the data of the sources from disk")
+	flag.DurationVar(&sources.DefaultMaxStaleAge, "max-stale-age", sources.DefaultMaxStaleAge, "keep using expired data of the sources that fail to refresh for up to `duration`")
+	flag.DurationVar(&httpOpts.Timeout, "timeout", httpOpts.Timeout, "timeout of every download attempt")
+	flag.IntVar(&httpOpts.Retries, "retries", httpOpts.Retries, "retries of downloads that fail with network errors or 5xx responses")
+	flag.StringVar(&httpOpts.Proxy, "proxy", "", "download through the proxy at `url` (default from HTTPS_PROXY)")
+	flag.StringVar(&httpOpts.CAFile, "ca-file", "", "trust the CA certificates of the PEM `file` besides the system ones")
+	flag.StringVar(&httpOpts.UserAgent, "user-agent", sources.DefaultUserAgent(), "User-Agent header of the downloads")
 	flag.Parse()
 
 	if showVersion {
 		utils.ShowVersion("whoip-server")
 		os.Exit(0)
 	}
+
+	switch {
+	case inMemory:
+		sources.SetDataDirectory("")
+	case dataDir != "":
+		sources.SetDataDirectory(dataDir)
+	}
+
+	if err := sources.SetHTTPOptions(httpOpts); err != nil {
+		log.Fatalf("Invalid HTTP options: %v", err)
+	}
+
+	if configFile != "" {
+		if err := sources.RegisterConfig(configFile); err != nil {
+			log.Fatalf("Invalid config: %v", err)
+		}
+	}
+
+	srv := &http.Server{
+		Addr:              listenAddr,
+		Handler:           newHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go whoip.RunRefresher(ctx)
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shutdown server: %v", err)
+		}
+	}()
+
+	log.Printf("Listening on '%s'.", listenAddr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Server error: %v", err)
+	}
 }
@@ -1,12 +1,16 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 )
 
-// GetDataDirectory creates and retrieves the whoip data directory
-func GetDataDirectory() string {
+// GetDataDirectory creates and retrieves the whoip data directory.
+// $WHOIP_DATA_DIR is used if set, otherwise the first usable of
+// '$XDG_DATA_HOME/whoip', '$HOME/.local/share/whoip' and '/tmp/whoip'.
+func GetDataDirectory() (string, error) {
 	createAndCheckDirectory := func(directory string) bool {
 		// Try to create the directory if it doesn't exist
 		if err := os.MkdirAll(directory, 0755); err != nil {
@@ -26,11 +30,18 @@ func GetDataDirectory() string {
 
 	var directory string
 
+	if directory = os.Getenv("WHOIP_DATA_DIR"); directory != "" {
+		if createAndCheckDirectory(directory) {
+			return directory, nil
+		}
+		return "", fmt.Errorf("failed to create data directory on '%s'", directory)
+	}
+
 	xdgDataHome := os.Getenv("XDG_DATA_HOME")
 	if xdgDataHome != "" {
 		directory = filepath.Join(xdgDataHome, "whoip")
 		if createAndCheckDirectory(directory) {
-			return directory
+			return directory, nil
 		}
 	}
 
@@ -38,14 +49,14 @@ func GetDataDirectory() string {
 	if home != "" {
 		directory = filepath.Join(home, ".local", "share", "whoip")
 		if createAndCheckDirectory(directory) {
-			return directory
+			return directory, nil
 		}
 	}
 
 	directory = filepath.Join("/tmp", "whoip")
 	if createAndCheckDirectory(directory) {
-		return directory
+		return directory, nil
 	}
 
-	panic("Failed to create data directory on '$XDG_DATA_HOME/whoip', '$HOME/.local/share/whoip' or '/tmp/whoip'.")
+	return "", errors.New("failed to create data directory on '$XDG_DATA_HOME/whoip', '$HOME/.local/share/whoip' or '/tmp/whoip'")
 }
@@ -13,3 +13,8 @@ var (
 func ShowVersion(progName string) {
 	fmt.Printf("%s %s\ncommit %s\nbuilt at %s\n", progName, version, commit, buildDate)
 }
+
+// Version returns the version of the build.
+func Version() string {
+	return version
+}
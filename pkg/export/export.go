@@ -0,0 +1,181 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/aorith/whoip/pkg/mmdb"
+	"github.com/aorith/whoip/pkg/whoip"
+)
+
+// Format is an export format.
+type Format string
+
+const (
+	FormatNftables Format = "nftables"
+	FormatIpset    Format = "ipset"
+	FormatIptables Format = "iptables"
+	FormatPf       Format = "pf"
+	FormatNginx    Format = "nginx"
+	FormatHAProxy  Format = "haproxy"
+	FormatCaddy    Format = "caddy"
+	FormatApache   Format = "apache"
+	FormatMMDB     Format = "mmdb"
+)
+
+// Options configures the generated output. Empty fields use their defaults.
+type Options struct {
+	Name   string // Name of the set or table, defaults to "whoip".
+	Chain  string // iptables chain of the rules, defaults to "INPUT".
+	Target string // iptables target of the rules, defaults to "DROP".
+	// Value selects the value associated to every prefix in web-server exports:
+	// "source" (default) for the source key, "category" for the category IDs or
+	// "detail:<Key>" for a field of the prefix details such as "detail:Service".
+	Value string
+}
+
+func (o Options) name() string {
+	if o.Name == "" {
+		return "whoip"
+	}
+	return o.Name
+}
+
+func (o Options) chain() string {
+	if o.Chain == "" {
+		return "INPUT"
+	}
+	return o.Chain
+}
+
+func (o Options) target() string {
+	if o.Target == "" {
+		return "DROP"
+	}
+	return o.Target
+}
+
+func (o Options) value() string {
+	if o.Value == "" {
+		return ValueSource
+	}
+	return o.Value
+}
+
+// Values of the Options.Value field.
+const (
+	ValueSource       = "source"
+	ValueCategory     = "category"
+	ValueDetailPrefix = "detail:"
+)
+
+// ValidateValue checks that the value is one of the supported Options.Value values.
+func ValidateValue(value string) error {
+	switch {
+	case value == "", value == ValueSource, value == ValueCategory:
+		return nil
+	case strings.HasPrefix(value, ValueDetailPrefix) && len(value) > len(ValueDetailPrefix):
+		return nil
+	}
+	return fmt.Errorf("invalid value: %s (valid values: %s, %s, %s<Key>)", value, ValueSource, ValueCategory, ValueDetailPrefix)
+}
+
+// header is written as a comment at the start of every export.
+const header = "Generated by whoip"
+
+// Formats lists the supported export formats.
+var Formats = []Format{FormatNftables, FormatIpset, FormatIptables, FormatPf, FormatNginx, FormatHAProxy, FormatCaddy, FormatApache, FormatMMDB}
+
+// Write writes the prefixes of the entries to w in the given format.
+func Write(w io.Writer, format Format, entries []whoip.Entry, opts Options) error {
+	if err := ValidateValue(opts.Value); err != nil {
+		return err
+	}
+
+	prefixes := entryPrefixes(entries)
+	switch format {
+	case FormatNftables:
+		return WriteNftables(w, prefixes, opts)
+	case FormatIpset:
+		return WriteIpset(w, prefixes, opts)
+	case FormatIptables:
+		return WriteIptables(w, prefixes, opts)
+	case FormatPf:
+		return WritePf(w, prefixes, opts)
+	case FormatNginx:
+		return WriteNginx(w, entries, opts)
+	case FormatHAProxy:
+		return WriteHAProxy(w, entries, opts)
+	case FormatCaddy:
+		return WriteCaddy(w, entries, opts)
+	case FormatApache:
+		return WriteApache(w, entries, opts)
+	case FormatMMDB:
+		return mmdb.Write(w, entries)
+	}
+	return fmt.Errorf("unknown export format: %s", format)
+}
+
+// Aggregate returns the smallest list of prefixes that covers exactly the same
+// addresses as the given prefixes, merging duplicated, nested and adjacent prefixes.
+// The result is sorted with IPv4 prefixes first.
+func Aggregate(prefixes []netip.Prefix) []netip.Prefix {
+	ranges := make([]whoip.Range, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p.IsValid() {
+			ranges = append(ranges, whoip.RangeFromPrefix(p))
+		}
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].From != ranges[j].From {
+			return ranges[i].From.Less(ranges[j].From)
+		}
+		return ranges[i].To.Less(ranges[j].To)
+	})
+
+	var merged []whoip.Range
+	for _, r := range ranges {
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			next := last.To.Next()
+			if last.From.Is4() == r.From.Is4() && (!next.IsValid() || !next.Less(r.From)) {
+				if last.To.Less(r.To) {
+					last.To = r.To
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+
+	var aggregated []netip.Prefix
+	for _, r := range merged {
+		aggregated = append(aggregated, r.Prefixes()...)
+	}
+	return aggregated
+}
+
+// SplitFamilies splits the prefixes into IPv4 and IPv6 prefixes.
+func SplitFamilies(prefixes []netip.Prefix) (v4, v6 []netip.Prefix) {
+	for _, p := range prefixes {
+		if p.Addr().Is4() {
+			v4 = append(v4, p)
+		} else {
+			v6 = append(v6, p)
+		}
+	}
+	return v4, v6
+}
+
+// entryPrefixes returns the prefixes of the entries.
+func entryPrefixes(entries []whoip.Entry) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(entries))
+	for i, e := range entries {
+		prefixes[i] = e.Prefix
+	}
+	return prefixes
+}
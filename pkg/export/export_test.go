@@ -0,0 +1,113 @@
+package export
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parsePrefixes(t *testing.T, list ...string) []netip.Prefix {
+	t.Helper()
+	var prefixes []netip.Prefix
+	for _, s := range list {
+		prefixes = append(prefixes, netip.MustParsePrefix(s))
+	}
+	return prefixes
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		input    []string
+		expected []string
+	}{
+		{
+			[]string{"10.0.1.0/24", "10.0.0.0/24"},
+			[]string{"10.0.0.0/23"},
+		},
+		{
+			[]string{"10.0.0.0/8", "10.1.0.0/16", "10.0.0.0/8"},
+			[]string{"10.0.0.0/8"},
+		},
+		{
+			[]string{"10.0.1.0/24", "10.0.2.0/24"},
+			[]string{"10.0.1.0/24", "10.0.2.0/24"},
+		},
+		{
+			[]string{"2001:db8:1::/48", "192.168.0.0/24", "2001:db8::/48", "192.168.1.0/24", "192.168.2.0/24"},
+			[]string{"192.168.0.0/23", "192.168.2.0/24", "2001:db8::/47"},
+		},
+		{
+			[]string{"255.255.255.255/32", "::/128", "255.255.255.254/32"},
+			[]string{"255.255.255.254/31", "::/128"},
+		},
+	}
+
+	for _, tt := range tests {
+		got := Aggregate(parsePrefixes(t, tt.input...))
+		expected := parsePrefixes(t, tt.expected...)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Wrong aggregation of %v. Expected: %v, Got: %v", tt.input, expected, got)
+		}
+	}
+}
+
+func TestWriteNftables(t *testing.T) {
+	var b strings.Builder
+	prefixes := parsePrefixes(t, "10.0.0.0/24", "10.0.1.0/24", "192.0.2.0/24")
+	if err := WriteNftables(&b, prefixes, Options{Name: "bots"}); err != nil {
+		t.Fatalf("Failed to write nftables: %v", err)
+	}
+
+	expected := `# Generated by whoip
+table inet bots {
+	set bots_v4 {
+		type ipv4_addr
+		flags interval
+		elements = {
+			10.0.0.0/23,
+			192.0.2.0/24
+		}
+	}
+	set bots_v6 {
+		type ipv6_addr
+		flags interval
+	}
+}
+`
+	if b.String() != expected {
+		t.Errorf("Wrong nftables output. Expected output:\n%s\n\nGot:\n%s\n", expected, b.String())
+	}
+}
+
+func TestWriteIpsetAndIptables(t *testing.T) {
+	prefixes := parsePrefixes(t, "192.0.2.0/24", "2001:db8::/32")
+
+	var b strings.Builder
+	if err := WriteIpset(&b, prefixes, Options{}); err != nil {
+		t.Fatalf("Failed to write ipset: %v", err)
+	}
+	for _, line := range []string{
+		"create whoip_v4 hash:net family inet maxelem 65536 -exist",
+		"add whoip_v4 192.0.2.0/24",
+		"create whoip_v6 hash:net family inet6 maxelem 65536 -exist",
+		"add whoip_v6 2001:db8::/32",
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("Missing line in ipset output: %s\n\nGot:\n%s", line, b.String())
+		}
+	}
+
+	b.Reset()
+	if err := WriteIptables(&b, prefixes, Options{Chain: "FORWARD", Target: "ACCEPT"}); err != nil {
+		t.Fatalf("Failed to write iptables: %v", err)
+	}
+	for _, line := range []string{
+		"iptables -A FORWARD -s 192.0.2.0/24 -j ACCEPT",
+		"ip6tables -A FORWARD -s 2001:db8::/32 -j ACCEPT",
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("Missing line in iptables output: %s\n\nGot:\n%s", line, b.String())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// WriteNftables writes an nftables table with one interval set per address family.
+func WriteNftables(w io.Writer, prefixes []netip.Prefix, opts Options) error {
+	v4, v6 := SplitFamilies(Aggregate(prefixes))
+	name := opts.name()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", header)
+	fmt.Fprintf(&b, "table inet %s {\n", name)
+	writeNftablesSet(&b, name+"_v4", "ipv4_addr", v4)
+	writeNftablesSet(&b, name+"_v6", "ipv6_addr", v6)
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeNftablesSet writes an nftables set of the given type.
+func writeNftablesSet(b *strings.Builder, name, setType string, prefixes []netip.Prefix) {
+	fmt.Fprintf(b, "\tset %s {\n", name)
+	fmt.Fprintf(b, "\t\ttype %s\n", setType)
+	b.WriteString("\t\tflags interval\n")
+	if len(prefixes) > 0 {
+		b.WriteString("\t\telements = {\n")
+		for i, p := range prefixes {
+			sep := ","
+			if i == len(prefixes)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(b, "\t\t\t%s%s\n", p, sep)
+		}
+		b.WriteString("\t\t}\n")
+	}
+	b.WriteString("\t}\n")
+}
+
+// WriteIpset writes an 'ipset restore' script with one hash:net set per address family.
+func WriteIpset(w io.Writer, prefixes []netip.Prefix, opts Options) error {
+	v4, v6 := SplitFamilies(Aggregate(prefixes))
+	name := opts.name()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", header)
+	writeIpsetSet(&b, name+"_v4", "inet", v4)
+	writeIpsetSet(&b, name+"_v6", "inet6", v6)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeIpsetSet writes the commands to create, flush and fill an ipset set.
+func writeIpsetSet(b *strings.Builder, name, family string, prefixes []netip.Prefix) {
+	// 65536 is the default maximum number of elements of a set.
+	maxElem := max(65536, len(prefixes))
+	fmt.Fprintf(b, "create %s hash:net family %s maxelem %d -exist\n", name, family, maxElem)
+	fmt.Fprintf(b, "flush %s\n", name)
+	for _, p := range prefixes {
+		fmt.Fprintf(b, "add %s %s\n", name, p)
+	}
+}
+
+// WriteIptables writes iptables and ip6tables commands with one rule per prefix.
+func WriteIptables(w io.Writer, prefixes []netip.Prefix, opts Options) error {
+	v4, v6 := SplitFamilies(Aggregate(prefixes))
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# %s\n", header)
+	for _, p := range v4 {
+		fmt.Fprintf(&b, "iptables -A %s -s %s -j %s\n", opts.chain(), p, opts.target())
+	}
+	for _, p := range v6 {
+		fmt.Fprintf(&b, "ip6tables -A %s -s %s -j %s\n", opts.chain(), p, opts.target())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WritePf writes a pf table file, load it with 'table <whoip> persist file "/path/to/file"'.
+func WritePf(w io.Writer, prefixes []netip.Prefix, opts Options) error {
+	v4, v6 := SplitFamilies(Aggregate(prefixes))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", header)
+	fmt.Fprintf(&b, "# table <%s> persist file \"/path/to/this/file\"\n", opts.name())
+	b.WriteString("# IPv4\n")
+	for _, p := range v4 {
+		fmt.Fprintf(&b, "%s\n", p)
+	}
+	b.WriteString("# IPv6\n")
+	for _, p := range v6 {
+		fmt.Fprintf(&b, "%s\n", p)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
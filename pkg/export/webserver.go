@@ -0,0 +1,178 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aorith/whoip/pkg/whoip"
+)
+
+// unknownValue is used for prefixes without the selected detail.
+const unknownValue = "unknown"
+
+// prefixesPerLine is the number of prefixes written on each line of the formats
+// that accept several prefixes per directive.
+const prefixesPerLine = 16
+
+// valueGroup holds the aggregated prefixes that share the same value.
+type valueGroup struct {
+	value    string
+	prefixes []netip.Prefix
+}
+
+// entryValue returns the value of the entry selected by the options.
+func entryValue(e whoip.Entry, opts Options) string {
+	value := opts.value()
+	switch {
+	case value == ValueCategory:
+		ids := make([]string, len(e.Categories))
+		for i, cat := range e.Categories {
+			ids[i] = cat.ID
+		}
+		if len(ids) == 0 {
+			return unknownValue
+		}
+		return strings.Join(ids, ",")
+	case strings.HasPrefix(value, ValueDetailPrefix):
+		if v := e.Details[strings.TrimPrefix(value, ValueDetailPrefix)]; v != "" {
+			return v
+		}
+		return unknownValue
+	}
+	return e.Source
+}
+
+// groupByValue groups the prefixes of the entries by their value, aggregating the
+// prefixes of every group. Groups are sorted by value.
+// A network is only written under one value: the value of its first entry, so
+// entries keep the precedence of their order. Aggregated prefixes that an earlier
+// value already covers with the same network are dropped too.
+func groupByValue(entries []whoip.Entry, opts Options) []valueGroup {
+	var order []string
+	seen := make(map[netip.Prefix]bool)
+	byValue := make(map[string][]netip.Prefix)
+	for _, e := range entries {
+		prefix := e.Prefix.Masked()
+		if seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+
+		value := entryValue(e, opts)
+		if _, ok := byValue[value]; !ok {
+			order = append(order, value)
+		}
+		byValue[value] = append(byValue[value], prefix)
+	}
+
+	claimed := make(map[netip.Prefix]bool)
+	groups := make([]valueGroup, 0, len(order))
+	for _, value := range order {
+		var prefixes []netip.Prefix
+		for _, p := range Aggregate(byValue[value]) {
+			if !claimed[p] {
+				claimed[p] = true
+				prefixes = append(prefixes, p)
+			}
+		}
+		if len(prefixes) > 0 {
+			groups = append(groups, valueGroup{value: value, prefixes: prefixes})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].value < groups[j].value
+	})
+	return groups
+}
+
+var identifierRe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// identifier returns s with every character not valid in a variable or matcher name replaced by '_'.
+func identifier(s string) string {
+	return strings.ToLower(identifierRe.ReplaceAllString(s, "_"))
+}
+
+// variableName returns the name of the variable that holds the value, e.g. "whoip_source".
+func variableName(opts Options) string {
+	return identifier(opts.name() + "_" + strings.TrimPrefix(opts.value(), ValueDetailPrefix))
+}
+
+// chunks splits the prefixes into lines of at most prefixesPerLine prefixes.
+func chunks(prefixes []netip.Prefix) [][]string {
+	var lines [][]string
+	for i := 0; i < len(prefixes); i += prefixesPerLine {
+		var line []string
+		for _, p := range prefixes[i:min(i+prefixesPerLine, len(prefixes))] {
+			line = append(line, p.String())
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// WriteNginx writes an nginx geo block that maps the client address to the selected value.
+func WriteNginx(w io.Writer, entries []whoip.Entry, opts Options) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", header)
+	fmt.Fprintf(&b, "geo $%s {\n", variableName(opts))
+	b.WriteString("\tdefault \"\";\n")
+	for _, group := range groupByValue(entries, opts) {
+		for _, p := range group.prefixes {
+			fmt.Fprintf(&b, "\t%s %q;\n", p, group.value)
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHAProxy writes an HAProxy map file, use it with 'src,map_ip(/path/to/file)'.
+func WriteHAProxy(w io.Writer, entries []whoip.Entry, opts Options) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", header)
+	for _, group := range groupByValue(entries, opts) {
+		value := strings.ReplaceAll(group.value, " ", "_")
+		for _, p := range group.prefixes {
+			fmt.Fprintf(&b, "%s %s\n", p, value)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteCaddy writes one Caddyfile named matcher per value with its remote_ip ranges.
+func WriteCaddy(w io.Writer, entries []whoip.Entry, opts Options) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", header)
+	for _, group := range groupByValue(entries, opts) {
+		fmt.Fprintf(&b, "@%s {\n", identifier(opts.name()+"_"+group.value))
+		for _, line := range chunks(group.prefixes) {
+			fmt.Fprintf(&b, "\tremote_ip %s\n", strings.Join(line, " "))
+		}
+		b.WriteString("}\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteApache writes one list of 'Require ip' directives per value.
+func WriteApache(w io.Writer, entries []whoip.Entry, opts Options) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", header)
+	for _, group := range groupByValue(entries, opts) {
+		fmt.Fprintf(&b, "# %s\n", group.value)
+		for _, line := range chunks(group.prefixes) {
+			fmt.Fprintf(&b, "Require ip %s\n", strings.Join(line, " "))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,146 @@
+package export
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/aorith/whoip/pkg/sources"
+	"github.com/aorith/whoip/pkg/whoip"
+)
+
+var testEntries = []whoip.Entry{
+	{
+		Source:     "aws",
+		Prefix:     netip.MustParsePrefix("3.5.140.0/22"),
+		Categories: []sources.Category{sources.Categories["datacenter"]},
+		Details:    map[string]string{"Service": "CLOUDFRONT"},
+	},
+	{
+		Source:     "aws",
+		Prefix:     netip.MustParsePrefix("3.5.144.0/22"),
+		Categories: []sources.Category{sources.Categories["datacenter"]},
+		Details:    map[string]string{"Service": "CLOUDFRONT"},
+	},
+	{
+		Source:     "aws",
+		Prefix:     netip.MustParsePrefix("2600:1f00::/24"),
+		Categories: []sources.Category{sources.Categories["datacenter"]},
+		Details:    map[string]string{"Service": "EC2"},
+	},
+	{
+		Source:     "bingbot",
+		Prefix:     netip.MustParsePrefix("157.55.39.0/24"),
+		Categories: []sources.Category{sources.Categories["crawler"]},
+	},
+}
+
+func TestWriteNginx(t *testing.T) {
+	var b strings.Builder
+	if err := Write(&b, FormatNginx, testEntries, Options{}); err != nil {
+		t.Fatalf("Failed to write nginx: %v", err)
+	}
+
+	expected := `# Generated by whoip
+geo $whoip_source {
+	default "";
+	3.5.140.0/22 "aws";
+	3.5.144.0/22 "aws";
+	2600:1f00::/24 "aws";
+	157.55.39.0/24 "bingbot";
+}
+`
+	if b.String() != expected {
+		t.Errorf("Wrong nginx output. Expected output:\n%s\n\nGot:\n%s\n", expected, b.String())
+	}
+}
+
+func TestWriteHAProxyDetailValue(t *testing.T) {
+	var b strings.Builder
+	if err := Write(&b, FormatHAProxy, testEntries, Options{Value: "detail:Service"}); err != nil {
+		t.Fatalf("Failed to write haproxy: %v", err)
+	}
+
+	expected := `# Generated by whoip
+3.5.140.0/22 CLOUDFRONT
+3.5.144.0/22 CLOUDFRONT
+2600:1f00::/24 EC2
+157.55.39.0/24 unknown
+`
+	if b.String() != expected {
+		t.Errorf("Wrong haproxy output. Expected output:\n%s\n\nGot:\n%s\n", expected, b.String())
+	}
+}
+
+func TestWriteCaddyAndApacheCategoryValue(t *testing.T) {
+	var b strings.Builder
+	if err := Write(&b, FormatCaddy, testEntries, Options{Value: "category"}); err != nil {
+		t.Fatalf("Failed to write caddy: %v", err)
+	}
+
+	expected := `# Generated by whoip
+@whoip_crawler {
+	remote_ip 157.55.39.0/24
+}
+@whoip_datacenter {
+	remote_ip 3.5.140.0/22 3.5.144.0/22 2600:1f00::/24
+}
+`
+	if b.String() != expected {
+		t.Errorf("Wrong caddy output. Expected output:\n%s\n\nGot:\n%s\n", expected, b.String())
+	}
+
+	b.Reset()
+	if err := Write(&b, FormatApache, testEntries, Options{Value: "category"}); err != nil {
+		t.Fatalf("Failed to write apache: %v", err)
+	}
+
+	expected = `# Generated by whoip
+# crawler
+Require ip 157.55.39.0/24
+# datacenter
+Require ip 3.5.140.0/22 3.5.144.0/22 2600:1f00::/24
+`
+	if b.String() != expected {
+		t.Errorf("Wrong apache output. Expected output:\n%s\n\nGot:\n%s\n", expected, b.String())
+	}
+}
+
+func TestWriteNginxDuplicatedNetworks(t *testing.T) {
+	entries := []whoip.Entry{
+		{Source: "aws", Prefix: netip.MustParsePrefix("3.5.140.0/22"), Details: map[string]string{"Service": "CLOUDFRONT"}},
+		{Source: "mirror", Prefix: netip.MustParsePrefix("3.5.140.0/22"), Details: map[string]string{"Service": "MIRROR"}},
+		{Source: "aws", Prefix: netip.MustParsePrefix("10.0.0.0/25"), Details: map[string]string{"Service": "EC2"}},
+		{Source: "aws", Prefix: netip.MustParsePrefix("10.0.0.128/25"), Details: map[string]string{"Service": "EC2"}},
+		{Source: "office", Prefix: netip.MustParsePrefix("10.0.0.0/24"), Details: map[string]string{"Service": "VPN"}},
+	}
+
+	var b strings.Builder
+	if err := Write(&b, FormatNginx, entries, Options{Value: "detail:Service"}); err != nil {
+		t.Fatalf("Failed to write nginx: %v", err)
+	}
+
+	expected := `# Generated by whoip
+geo $whoip_service {
+	default "";
+	3.5.140.0/22 "CLOUDFRONT";
+	10.0.0.0/24 "EC2";
+}
+`
+	if b.String() != expected {
+		t.Errorf("Wrong nginx output. Expected output:\n%s\n\nGot:\n%s\n", expected, b.String())
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	for _, value := range []string{"", "source", "category", "detail:Service"} {
+		if err := ValidateValue(value); err != nil {
+			t.Errorf("Unexpected error for value '%s': %v", value, err)
+		}
+	}
+	for _, value := range []string{"foo", "detail:"} {
+		if err := ValidateValue(value); err == nil {
+			t.Errorf("Expected an error for value '%s'", value)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Data types of the MaxMind DB data section.
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBool     = 14
+	typeFloat    = 15
+)
+
+// encoder serializes values to the MaxMind DB data format.
+// Supported values are string, bool, uint16, uint32, uint64, int32, float64,
+// []any, []string, map[string]any and map[string]string.
+type encoder struct {
+	buf []byte
+}
+
+// encode appends the encoded value to the buffer.
+func (e *encoder) encode(v any) error {
+	switch v := v.(type) {
+	case string:
+		e.control(typeString, len(v))
+		e.buf = append(e.buf, v...)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		e.control(typeBool, size)
+	case uint16:
+		e.uint(typeUint16, uint64(v))
+	case uint32:
+		e.uint(typeUint32, uint64(v))
+	case uint64:
+		e.uint(typeUint64, v)
+	case int32:
+		e.control(typeInt32, 4)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	case float64:
+		e.control(typeDouble, 8)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+	case []string:
+		e.control(typeArray, len(v))
+		for _, item := range v {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+	case []any:
+		e.control(typeArray, len(v))
+		for _, item := range v {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+	case map[string]string:
+		e.control(typeMap, len(v))
+		for _, k := range sortedKeys(v) {
+			e.encode(k)
+			e.encode(v[k])
+		}
+	case map[string]any:
+		e.control(typeMap, len(v))
+		for _, k := range sortedKeys(v) {
+			e.encode(k)
+			if err := e.encode(v[k]); err != nil {
+				return fmt.Errorf("key '%s': %w", k, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+// uint appends an unsigned integer using the minimum number of bytes.
+func (e *encoder) uint(typ int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	size := 8
+	for size > 0 && b[8-size] == 0 {
+		size--
+	}
+	e.control(typ, size)
+	e.buf = append(e.buf, b[8-size:]...)
+}
+
+// control appends the control byte of the type and size.
+func (e *encoder) control(typ, size int) {
+	first := byte(typ << 5)
+	if typ > 7 {
+		first = 0
+	}
+
+	var extra []byte
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 29+256:
+		first |= 29
+		extra = []byte{byte(size - 29)}
+	case size < 285+65536:
+		first |= 30
+		s := size - 285
+		extra = []byte{byte(s >> 8), byte(s)}
+	default:
+		first |= 31
+		s := size - 65821
+		extra = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+	}
+
+	e.buf = append(e.buf, first)
+	if typ > 7 {
+		e.buf = append(e.buf, byte(typ-7))
+	}
+	e.buf = append(e.buf, extra...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var errInvalidData = errors.New("invalid data section")
+
+// decoder deserializes values from a MaxMind DB data section.
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at the offset and the offset right after it.
+// Maps are returned as map[string]any, arrays as []any and integers as uint64 or int32.
+func (d *decoder) decode(offset int) (any, int, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		ptr, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr)
+		return v, next, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for i := 0; i < size; i++ {
+			k, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errInvalidData
+			}
+			v, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, size)
+		for i := 0; i < size; i++ {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > len(d.buf) {
+		return nil, 0, errInvalidData
+	}
+	data := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case typeString:
+		return string(data), next, nil
+	case typeBytes:
+		return append([]byte(nil), data...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errInvalidData
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errInvalidData
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errInvalidData
+		}
+		var v uint64
+		for _, b := range data {
+			v = v<<8 | uint64(b)
+		}
+		return v, next, nil
+	case typeInt32:
+		var v uint32
+		for _, b := range data {
+			v = v<<8 | uint32(b)
+		}
+		return int32(v), next, nil
+	case typeUint128:
+		return append([]byte(nil), data...), next, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+}
+
+// control reads a control byte and returns the type, the size and the offset of the payload.
+func (d *decoder) control(offset int) (int, int, int, error) {
+	if offset >= len(d.buf) {
+		return 0, 0, 0, errInvalidData
+	}
+	first := d.buf[offset]
+	offset++
+
+	typ := int(first >> 5)
+	if typ == typeExtended {
+		if offset >= len(d.buf) {
+			return 0, 0, 0, errInvalidData
+		}
+		typ = int(d.buf[offset]) + 7
+		offset++
+	}
+
+	size := int(first & 0x1f)
+	if typ == typePointer {
+		return typ, size, offset, nil
+	}
+
+	extra := 0
+	switch size {
+	case 29:
+		extra = 1
+	case 30:
+		extra = 2
+	case 31:
+		extra = 3
+	}
+	if offset+extra > len(d.buf) {
+		return 0, 0, 0, errInvalidData
+	}
+	switch size {
+	case 29:
+		size = 29 + int(d.buf[offset])
+	case 30:
+		size = 285 + (int(d.buf[offset])<<8 | int(d.buf[offset+1]))
+	case 31:
+		size = 65821 + (int(d.buf[offset])<<16 | int(d.buf[offset+1])<<8 | int(d.buf[offset+2]))
+	}
+	return typ, size, offset + extra, nil
+}
+
+// pointer decodes a pointer from the size bits of its control byte and returns
+// the pointed offset and the offset after the pointer.
+func (d *decoder) pointer(size, offset int) (int, int, error) {
+	n := (size>>3)&0x3 + 1
+	if offset+n > len(d.buf) {
+		return 0, 0, errInvalidData
+	}
+	v := 0
+	if n < 4 {
+		v = size & 0x7
+	}
+	for _, b := range d.buf[offset : offset+n] {
+		v = v<<8 | int(b)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
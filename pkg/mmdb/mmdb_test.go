@@ -0,0 +1,169 @@
+package mmdb
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aorith/whoip/pkg/sources"
+	"github.com/aorith/whoip/pkg/whoip"
+)
+
+// networksOf returns the networks of the sources stored in a record.
+func networksOf(t *testing.T, v any) []string {
+	t.Helper()
+	if v == nil {
+		return nil
+	}
+	record, ok := v.(map[string]any)
+	if !ok {
+		t.Fatalf("Unexpected record type %T", v)
+	}
+	var networks []string
+	for _, src := range record["sources"].([]any) {
+		networks = append(networks, src.(map[string]any)["network"].(string))
+	}
+	return networks
+}
+
+func TestWriteAndLookup(t *testing.T) {
+	entries := []whoip.Entry{
+		{
+			Source:     "aws",
+			Name:       "Amazon AWS",
+			Prefix:     netip.MustParsePrefix("3.0.0.0/8"),
+			Categories: []sources.Category{sources.Categories["datacenter"]},
+			Details:    map[string]string{"Service": "AMAZON"},
+		},
+		{
+			Source:     "aws",
+			Name:       "Amazon AWS",
+			Prefix:     netip.MustParsePrefix("3.5.140.0/22"),
+			Categories: []sources.Category{sources.Categories["datacenter"]},
+			Details:    map[string]string{"Service": "CLOUDFRONT", "Region": strings.Repeat("long-region-", 30)},
+		},
+		{
+			Source:     "bingbot",
+			Name:       "BingBot",
+			Prefix:     netip.MustParsePrefix("3.5.140.0/24"),
+			Categories: []sources.Category{sources.Categories["crawler"]},
+		},
+		{
+			Source: "v6",
+			Name:   "IPv6 Source",
+			Prefix: netip.MustParsePrefix("2600:1f00::/24"),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, entries); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+
+	r, err := NewReader(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to read database: %v", err)
+	}
+	if r.Metadata["database_type"] != DatabaseType {
+		t.Errorf("Wrong database type: %v", r.Metadata["database_type"])
+	}
+
+	tests := []struct {
+		addr     string
+		network  string
+		expected []string
+	}{
+		{"3.5.140.10", "3.5.140.0/24", []string{"3.5.140.0/24", "3.5.140.0/22", "3.0.0.0/8"}},
+		{"3.5.141.10", "3.5.141.0/24", []string{"3.5.140.0/22", "3.0.0.0/8"}},
+		{"3.200.0.1", "3.128.0.0/9", []string{"3.0.0.0/8"}},
+		{"::ffff:3.1.1.1", "3.0.0.0/14", []string{"3.0.0.0/8"}},
+		{"2600:1f00::1", "2600:1f00::/24", []string{"2600:1f00::/24"}},
+		{"192.0.2.1", "128.0.0.0/1", nil},
+	}
+
+	for _, tt := range tests {
+		v, network, err := r.Lookup(netip.MustParseAddr(tt.addr))
+		if err != nil {
+			t.Errorf("Failed to lookup '%s': %v", tt.addr, err)
+			continue
+		}
+		if got := networksOf(t, v); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Wrong record for '%s'. Expected: %v, Got: %v", tt.addr, tt.expected, got)
+		}
+		if network.String() != tt.network {
+			t.Errorf("Wrong network for '%s'. Expected: %s, Got: %s", tt.addr, tt.network, network)
+		}
+	}
+
+	v, _, _ := r.Lookup(netip.MustParseAddr("3.5.140.10"))
+	cloudfront := v.(map[string]any)["sources"].([]any)[1].(map[string]any)
+	if cloudfront["details"].(map[string]any)["Region"] != entries[1].Details["Region"] {
+		t.Errorf("Wrong details: %v", cloudfront["details"])
+	}
+	if !reflect.DeepEqual(cloudfront["categories"], []any{"datacenter"}) {
+		t.Errorf("Wrong categories: %v", cloudfront["categories"])
+	}
+}
+
+func TestWriteAndLookupMatchesLinearScan(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+
+	var entries []whoip.Entry
+	for i := 0; i < 1000; i++ {
+		var addr netip.Addr
+		if i%2 == 0 {
+			addr = netip.AddrFrom4([4]byte{10, byte(r.IntN(4)), byte(r.IntN(256)), 0})
+		} else {
+			addr = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(r.IntN(4)), byte(r.IntN(256))})
+		}
+		prefix, _ := addr.Prefix(8 + r.IntN(addr.BitLen()/2))
+		entries = append(entries, whoip.Entry{Source: fmt.Sprintf("src%d", i%7), Prefix: prefix.Masked()})
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, entries); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+	reader, err := NewReader(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to read database: %v", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		var addr netip.Addr
+		if i%2 == 0 {
+			addr = netip.AddrFrom4([4]byte{10, byte(r.IntN(4)), byte(r.IntN(256)), byte(r.IntN(256))})
+		} else {
+			addr = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, byte(r.IntN(4)), byte(r.IntN(256)), byte(r.IntN(256))})
+		}
+
+		var matches []netip.Prefix
+		for _, e := range entries {
+			if e.Prefix.Contains(addr) {
+				matches = append(matches, e.Prefix)
+			}
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Bits() > matches[j].Bits()
+		})
+
+		v, _, err := reader.Lookup(addr)
+		if err != nil {
+			t.Fatalf("Failed to lookup '%s': %v", addr, err)
+		}
+		got := networksOf(t, v)
+		if len(got) != len(matches) {
+			t.Fatalf("Wrong number of networks for '%s'. Expected: %v, Got: %v", addr, matches, got)
+		}
+		for i, network := range got {
+			if netip.MustParsePrefix(network).Bits() != matches[i].Bits() {
+				t.Fatalf("Wrong networks for '%s'. Expected: %v, Got: %v", addr, matches, got)
+			}
+		}
+	}
+}
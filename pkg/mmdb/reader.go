@@ -0,0 +1,145 @@
+package mmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+)
+
+// Reader looks up addresses in a MaxMind DB database.
+type Reader struct {
+	Metadata   map[string]any
+	tree       []byte
+	data       decoder
+	nodeCount  int
+	recordSize int
+	ipVersion  int
+	ipv4Start  int
+}
+
+// Open reads the database file at the given path.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database: %v", err)
+	}
+	return NewReader(buf)
+}
+
+// NewReader returns a Reader of the database in buf.
+func NewReader(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, errors.New("metadata not found")
+	}
+
+	meta := decoder{buf: buf[i+len(metadataMarker):]}
+	v, _, err := meta.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %v", err)
+	}
+	metadata, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid metadata")
+	}
+
+	number := func(key string) int {
+		n, _ := metadata[key].(uint64)
+		return int(n)
+	}
+	r := &Reader{
+		Metadata:   metadata,
+		nodeCount:  number("node_count"),
+		recordSize: number("record_size"),
+		ipVersion:  number("ip_version"),
+	}
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size: %d", r.recordSize)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > i {
+		return nil, errors.New("invalid search tree size")
+	}
+	r.tree = buf[:treeSize]
+	r.data = decoder{buf: buf[treeSize+dataSectionSeparator : i]}
+
+	// IPv4 addresses are looked up from the node of ::/96.
+	if r.ipVersion == 6 {
+		node := 0
+		for depth := 0; depth < 96 && node < r.nodeCount; depth++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// record returns the left (0) or right (1) record of the node.
+func (r *Reader) record(node, bit int) int {
+	switch r.recordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return int(b[3]>>4)<<24 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+		}
+		return int(b[3]&0x0f)<<24 | int(b[4])<<16 | int(b[5])<<8 | int(b[6])
+	}
+	return int(binary.BigEndian.Uint32(r.tree[node*8+bit*4:]))
+}
+
+// Lookup returns the data of the address and the network of the database that
+// contains it. The data is nil if the address is not in the database.
+func (r *Reader) Lookup(addr netip.Addr) (any, netip.Prefix, error) {
+	addr = addr.Unmap()
+
+	var bits [16]byte
+	bitLen, node, depth := 128, 0, 0
+	switch {
+	case addr.Is4() && r.ipVersion == 4:
+		v4 := addr.As4()
+		copy(bits[:], v4[:])
+		bitLen = 32
+	case addr.Is4():
+		v4 := addr.As4()
+		copy(bits[12:], v4[:])
+		node, depth = r.ipv4Start, 96
+	case r.ipVersion == 4:
+		return nil, netip.Prefix{}, errors.New("IPv6 address in an IPv4-only database")
+	default:
+		bits = addr.As16()
+	}
+
+	for node < r.nodeCount && depth < bitLen {
+		node = r.record(node, int(bits[depth/8]>>(7-depth%8))&1)
+		depth++
+	}
+
+	prefixBits := depth
+	if addr.Is4() && r.ipVersion == 6 {
+		prefixBits -= 96
+	}
+	network, _ := addr.Prefix(prefixBits)
+
+	if node == r.nodeCount {
+		return nil, network, nil
+	}
+	if node < r.nodeCount {
+		return nil, netip.Prefix{}, errors.New("invalid search tree")
+	}
+
+	v, _, err := r.data.decode(node - r.nodeCount - dataSectionSeparator)
+	if err != nil {
+		return nil, netip.Prefix{}, fmt.Errorf("failed to decode data: %v", err)
+	}
+	return v, network, nil
+}
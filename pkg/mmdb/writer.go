@@ -0,0 +1,216 @@
+// Package mmdb writes and reads MaxMind DB (.mmdb) files with the whoip dataset.
+//
+// Every network of the database maps to a record with the matching prefixes of
+// all the sources, from the most to the least specific:
+//
+//	{"sources": [{"source": "aws", "name": "Amazon AWS", "network": "3.5.140.0/22",
+//	  "categories": ["datacenter"], "details": {"Service": "CLOUDFRONT", ...}}, ...]}
+package mmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"time"
+
+	"github.com/aorith/whoip/pkg/whoip"
+)
+
+// metadataMarker separates the data section from the metadata.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the number of zero bytes between the search tree and the data section.
+const dataSectionSeparator = 16
+
+// DatabaseType is the database_type stored in the metadata.
+const DatabaseType = "whoip"
+
+// treeNode is a node of the search tree. Leaves have no children.
+type treeNode struct {
+	children [2]*treeNode
+	record   int // Index of the record of a leaf, -1 if the network has no data.
+}
+
+// Write compiles the entries into a MaxMind DB database and writes it to w.
+func Write(w io.Writer, entries []whoip.Entry) error {
+	// Group the entries by network and insert the networks from the least to the
+	// most specific, so nested networks inherit the data of their containers.
+	byPrefix := make(map[netip.Prefix][]whoip.Entry)
+	for _, e := range entries {
+		p := treePrefix(e.Prefix)
+		byPrefix[p] = append(byPrefix[p], e)
+	}
+	prefixes := make([]netip.Prefix, 0, len(byPrefix))
+	for p := range byPrefix {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if prefixes[i].Bits() != prefixes[j].Bits() {
+			return prefixes[i].Bits() < prefixes[j].Bits()
+		}
+		return prefixes[i].Addr().Less(prefixes[j].Addr())
+	})
+
+	root := &treeNode{record: -1}
+	var records [][]whoip.Entry
+	for _, p := range prefixes {
+		n := root
+		addr := p.Addr().As16()
+		for depth := 0; depth < p.Bits(); depth++ {
+			if n.children[0] == nil {
+				n.children[0] = &treeNode{record: n.record}
+				n.children[1] = &treeNode{record: n.record}
+				n.record = -1
+			}
+			n = n.children[int(addr[depth/8]>>(7-depth%8))&1]
+		}
+
+		record := append([]whoip.Entry(nil), byPrefix[p]...)
+		if n.record >= 0 {
+			record = append(record, records[n.record]...)
+		}
+		records = append(records, record)
+		n.record = len(records) - 1
+	}
+
+	// Serialize the records, sharing identical ones.
+	var data encoder
+	offsets := make([]int, len(records))
+	seen := make(map[string]int)
+	for i, record := range records {
+		var e encoder
+		if err := e.encode(recordValue(record)); err != nil {
+			return fmt.Errorf("failed to encode record: %v", err)
+		}
+		if offset, ok := seen[string(e.buf)]; ok {
+			offsets[i] = offset
+			continue
+		}
+		offsets[i] = len(data.buf)
+		seen[string(e.buf)] = offsets[i]
+		data.buf = append(data.buf, e.buf...)
+	}
+
+	// Number the internal nodes, the root is always the node 0.
+	var nodes []*treeNode
+	index := make(map[*treeNode]int)
+	queue := []*treeNode{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n.children[0] == nil {
+			continue
+		}
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		queue = append(queue, n.children[0], n.children[1])
+	}
+	if len(nodes) == 0 {
+		// The tree needs at least one node, split the root.
+		root.children[0] = &treeNode{record: root.record}
+		root.children[1] = &treeNode{record: root.record}
+		index[root] = 0
+		nodes = append(nodes, root)
+	}
+
+	nodeCount := len(nodes)
+	pointer := func(n *treeNode) uint64 {
+		if n.children[0] != nil {
+			return uint64(index[n])
+		}
+		if n.record < 0 {
+			return uint64(nodeCount)
+		}
+		return uint64(nodeCount + dataSectionSeparator + offsets[n.record])
+	}
+
+	maxValue := uint64(nodeCount + dataSectionSeparator + len(data.buf))
+	recordSize := 24
+	switch {
+	case maxValue >= 1<<28:
+		recordSize = 32
+	case maxValue >= 1<<24:
+		recordSize = 28
+	}
+	if maxValue >= 1<<32 {
+		return fmt.Errorf("database too big")
+	}
+
+	tree := make([]byte, 0, nodeCount*recordSize/4)
+	for _, n := range nodes {
+		tree = appendNode(tree, recordSize, pointer(n.children[0]), pointer(n.children[1]))
+	}
+
+	var meta encoder
+	err := meta.encode(map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               DatabaseType,
+		"description":                 map[string]string{"en": "whoip IP ranges of cloud providers, crawlers and other sources"},
+		"ip_version":                  uint16(6),
+		"languages":                   []string{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %v", err)
+	}
+
+	for _, b := range [][]byte{tree, make([]byte, dataSectionSeparator), data.buf, metadataMarker, meta.buf} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// treePrefix returns the prefix in the IPv6 search tree, IPv4 networks are stored under ::/96.
+func treePrefix(p netip.Prefix) netip.Prefix {
+	p = p.Masked()
+	if p.Addr().Is4() {
+		var b [16]byte
+		v4 := p.Addr().As4()
+		copy(b[12:], v4[:])
+		return netip.PrefixFrom(netip.AddrFrom16(b), p.Bits()+96)
+	}
+	return p
+}
+
+// recordValue returns the data stored for the entries of a network.
+func recordValue(entries []whoip.Entry) map[string]any {
+	srcs := make([]any, len(entries))
+	for i, e := range entries {
+		categories := make([]string, len(e.Categories))
+		for j, cat := range e.Categories {
+			categories[j] = cat.ID
+		}
+		details := e.Details
+		if details == nil {
+			details = map[string]string{}
+		}
+		srcs[i] = map[string]any{
+			"source":     e.Source,
+			"name":       e.Name,
+			"network":    e.Prefix.String(),
+			"categories": categories,
+			"details":    details,
+		}
+	}
+	return map[string]any{"sources": srcs}
+}
+
+// appendNode appends a node with the left and right records of the given size in bits.
+func appendNode(b []byte, recordSize int, left, right uint64) []byte {
+	switch recordSize {
+	case 24:
+		return append(b, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+	case 28:
+		return append(b, byte(left>>16), byte(left>>8), byte(left),
+			byte((left>>24)<<4|(right>>24)&0x0f), byte(right>>16), byte(right>>8), byte(right))
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(left))
+	return binary.BigEndian.AppendUint32(b, uint32(right))
+}
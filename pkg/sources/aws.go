@@ -3,35 +3,26 @@ package sources
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
-	"net/http"
 	"time"
 )
 
-// fetchAWSData fetches the AWS data and updates the MetaData.
-func fetchAWSData(src *IPSource) error {
-	src.Mu.Lock()
-	defer src.Mu.Unlock()
+// awsTimeLayout is the layout of the createDate of the AWS ip-ranges.json file, in UTC.
+const awsTimeLayout = "2006-01-02-15-04-05"
 
-	if time.Since(src.MetaData.LastUpdate) < src.RefreshInterval {
-		return nil // Data is up to date
-	}
-
-	if src.load() {
-		// Data is still valid
-		return nil
-	}
-
-	resp, err := http.Get(src.URL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch data: %v", err)
-	}
-	defer resp.Body.Close()
+// awsUmbrellaService is the service that lists every prefix of AWS, including
+// those of the other services.
+const awsUmbrellaService = "AMAZON"
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("received non-200 response code: %d", resp.StatusCode)
-	}
+// awsServiceCategories overrides the categories of the prefixes of some services.
+var awsServiceCategories = map[string][]Category{
+	"CLOUDFRONT":               {Categories["cdn"]},
+	"CLOUDFRONT_ORIGIN_FACING": {Categories["cdn"]},
+}
 
+// parseAWS parses the AWS ip-ranges.json file.
+func parseAWS(r io.Reader) (*Data, error) {
 	var fetchedData struct {
 		SyncToken  string `json:"syncToken"`
 		CreateDate string `json:"createDate"`
@@ -43,13 +34,25 @@ func fetchAWSData(src *IPSource) error {
 		} `json:"prefixes"`
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&fetchedData)
+	err := json.NewDecoder(r).Decode(&fetchedData)
 	if err != nil {
-		return fmt.Errorf("failed to decode json: %v", err)
+		return nil, fmt.Errorf("failed to decode json: %v", err)
+	}
+
+	// The AMAZON copies of the prefixes of other services are dropped, so every
+	// network reports the service that actually uses it.
+	services := make(map[string]bool)
+	for _, p := range fetchedData.Prefixes {
+		if p.Service != awsUmbrellaService {
+			services[p.IPPrefix] = true
+		}
 	}
 
 	var prefixes []Prefix
 	for _, p := range fetchedData.Prefixes {
+		if p.Service == awsUmbrellaService && services[p.IPPrefix] {
+			continue
+		}
 		_, network, err := net.ParseCIDR(p.IPPrefix)
 		if err != nil {
 			continue
@@ -61,12 +64,10 @@ func fetchAWSData(src *IPSource) error {
 				"Service":            p.Service,
 				"NetworkBorderGroup": p.NetworkBorderGroup,
 			},
+			Categories: awsServiceCategories[p.Service],
 		})
 	}
 
-	src.MetaData.Prefixes = prefixes
-	src.MetaData.LastUpdate = time.Now()
-	src.mustSave()
-
-	return nil
+	published, _ := time.Parse(awsTimeLayout, fetchedData.CreateDate)
+	return &Data{Prefixes: prefixes, SyncToken: fetchedData.SyncToken, Published: published}, nil
 }
@@ -0,0 +1,119 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// azureDownloadURL is the download page of the Azure Service Tags of the public cloud.
+// The URL of the JSON file changes every week, so it is looked up on this page.
+const azureDownloadURL = "https://www.microsoft.com/en-us/download/details.aspx?id=56519"
+
+// azureFileRe matches the URL of the JSON file in the download page.
+var azureFileRe = regexp.MustCompile(`https://download\.microsoft\.com/download/[^"'\s<>]+/ServiceTags_Public_\d+\.json`)
+
+// azureServiceCategories overrides the categories of the prefixes of some services.
+var azureServiceCategories = map[string][]Category{
+	"AzureFrontDoor": {Categories["cdn"]},
+}
+
+// azureSource is the Source of the Azure Service Tags.
+// The URL can be the download page, the URL of the JSON file or the path of a
+// local copy of it.
+type azureSource struct {
+	ParserFunc
+}
+
+// Fetch downloads the JSON file, looking up its current URL if url is the download page.
+func (a azureSource) Fetch(ctx context.Context, url string, cached Validators) (io.ReadCloser, Validators, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return fetchFile(url, cached)
+	}
+
+	if !strings.HasSuffix(url, ".json") {
+		fileURL, err := azureFileURL(ctx, url)
+		if err != nil {
+			return nil, Validators{}, err
+		}
+		url = fileURL
+	}
+	return fetchURL(ctx, url, cached)
+}
+
+// azureFileURL returns the URL of the JSON file linked from the download page.
+func azureFileURL(ctx context.Context, pageURL string) (string, error) {
+	body, _, err := fetchURL(ctx, pageURL, Validators{})
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	page, err := io.ReadAll(io.LimitReader(body, 4<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read download page: %v", err)
+	}
+
+	fileURL := azureFileRe.Find(page)
+	if fileURL == nil {
+		return "", errors.New("failed to find the service tags file in the download page")
+	}
+	return string(fileURL), nil
+}
+
+// parseAzure parses the Azure Service Tags JSON file.
+func parseAzure(r io.Reader) (*Data, error) {
+	var fetchedData struct {
+		ChangeNumber int `json:"changeNumber"`
+		Values       []struct {
+			Name       string `json:"name"`
+			Properties struct {
+				Region          string   `json:"region"`
+				SystemService   string   `json:"systemService"`
+				AddressPrefixes []string `json:"addressPrefixes"`
+			} `json:"properties"`
+		} `json:"values"`
+	}
+
+	err := json.NewDecoder(r).Decode(&fetchedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode json: %v", err)
+	}
+
+	var prefixes []Prefix
+	for _, v := range fetchedData.Values {
+		details := map[string]string{"Tag": v.Name}
+		if v.Properties.Region != "" {
+			details["Region"] = v.Properties.Region
+		}
+		if v.Properties.SystemService != "" {
+			details["Service"] = v.Properties.SystemService
+		}
+		categories := azureServiceCategories[v.Properties.SystemService]
+
+		for _, s := range v.Properties.AddressPrefixes {
+			network, ok := parseNetwork(s)
+			if !ok {
+				continue
+			}
+			prefixes = append(prefixes, Prefix{
+				Network:    *network,
+				Details:    details,
+				Categories: categories,
+			})
+		}
+	}
+
+	// Every prefix is also listed by the AzureCloud tags, and often by the
+	// regional tags of its service.
+	data := &Data{Prefixes: mergePrefixes(prefixes, "Tag")}
+	if fetchedData.ChangeNumber > 0 {
+		data.SyncToken = strconv.Itoa(fetchedData.ChangeNumber)
+	}
+	return data, nil
+}
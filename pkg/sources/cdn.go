@@ -0,0 +1,98 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// textLists is a Source that downloads the URL of the source and the extra URLs,
+// like the separate IPv4 and IPv6 lists of a provider, and parses them as a
+// single plain-text list.
+// The lists are always downloaded, conditional requests are not used.
+type textLists struct {
+	Extra  []string
+	Parser ParserFunc // Parses the joined lists, parseText if nil.
+}
+
+// Fetch downloads all the lists.
+func (l textLists) Fetch(ctx context.Context, url string, cached Validators) (io.ReadCloser, Validators, error) {
+	var buf bytes.Buffer
+	for _, u := range append([]string{url}, l.Extra...) {
+		body, _, err := fetchURL(ctx, u, Validators{})
+		if err != nil {
+			return nil, Validators{}, err
+		}
+		_, err = io.Copy(&buf, body)
+		body.Close()
+		if err != nil {
+			return nil, Validators{}, fmt.Errorf("failed to read data: %v", err)
+		}
+		buf.WriteByte('\n') // The lists may not end with a newline.
+	}
+	return io.NopCloser(&buf), Validators{}, nil
+}
+
+// Parse parses the lists.
+func (l textLists) Parse(r io.Reader) (*Data, error) {
+	if l.Parser != nil {
+		return l.Parser(r)
+	}
+	return parseText(r)
+}
+
+// parseBunny parses the Bunny CDN edge server lists, the IPv4 list is plain
+// text and the IPv6 list a JSON array of addresses.
+func parseBunny(r io.Reader) (*Data, error) {
+	var prefixes []Prefix
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 4<<20) // The JSON array is a single line.
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		addresses := []string{line}
+		if strings.HasPrefix(line, "[") {
+			if err := json.Unmarshal([]byte(line), &addresses); err != nil {
+				return nil, fmt.Errorf("failed to decode json: %v", err)
+			}
+		}
+		for _, s := range addresses {
+			network, ok := parseNetwork(s)
+			if !ok {
+				continue
+			}
+			prefixes = append(prefixes, Prefix{Network: *network})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read data: %v", err)
+	}
+	return &Data{Prefixes: prefixes}, nil
+}
+
+// parseFastly parses the Fastly public-ip-list file.
+func parseFastly(r io.Reader) (*Data, error) {
+	var fetchedData struct {
+		Addresses     []string `json:"addresses"`
+		IPv6Addresses []string `json:"ipv6_addresses"`
+	}
+
+	err := json.NewDecoder(r).Decode(&fetchedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode json: %v", err)
+	}
+
+	var prefixes []Prefix
+	for _, s := range append(fetchedData.Addresses, fetchedData.IPv6Addresses...) {
+		network, ok := parseNetwork(s)
+		if !ok {
+			continue
+		}
+		prefixes = append(prefixes, Prefix{Network: *network})
+	}
+
+	return &Data{Prefixes: prefixes}, nil
+}
@@ -0,0 +1,254 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the data of the sources defined in a config file.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatText = "text"
+	// FormatGeofeed is an RFC 8805 geofeed, a CSV file with the location of each prefix.
+	FormatGeofeed = "geofeed"
+	// FormatAzure is the Azure Service Tags JSON file, to read a local copy of it.
+	FormatAzure = "azure"
+)
+
+// defaultConfigRefreshInterval is the refresh interval of config sources that do not set one.
+const defaultConfigRefreshInterval = 24 * time.Hour
+
+// Config holds the sources defined in a config file.
+type Config struct {
+	Sources []SourceConfig `json:"sources" yaml:"sources" toml:"sources"`
+}
+
+// SourceConfig defines a source in a config file.
+//
+// JSON data is read with JSONParser: Items is the path to the list of prefixes
+// and Network the path of the prefix in each item, with alternatives separated by '|'.
+// CSV data is read with CSVParser: Network is the column of the prefix.
+// Text data has a prefix or address per line.
+// Geofeed data is read with the country, region, city and postal code of each prefix as details.
+// Azure data is an Azure Service Tags JSON file.
+// Details maps the name of each detail to its path or column.
+//
+// A source with Replace set replaces the registered source with the same key,
+// for example to read a built-in source from a local file. The name, description
+// and categories of the replaced source are kept unless set.
+type SourceConfig struct {
+	Key             string            `json:"key" yaml:"key" toml:"key"`
+	Name            string            `json:"name" yaml:"name" toml:"name"`
+	Description     string            `json:"description" yaml:"description" toml:"description"`
+	URL             string            `json:"url" yaml:"url" toml:"url"`
+	Path            string            `json:"path" yaml:"path" toml:"path"` // Local file, used instead of URL.
+	Format          string            `json:"format" yaml:"format" toml:"format"`
+	Categories      []string          `json:"categories" yaml:"categories" toml:"categories"`
+	RefreshInterval string            `json:"refresh_interval" yaml:"refresh_interval" toml:"refresh_interval"` // Defaults to 24h.
+	MaxStaleAge     string            `json:"max_stale_age" yaml:"max_stale_age" toml:"max_stale_age"`          // Defaults to DefaultMaxStaleAge.
+	Items           string            `json:"items" yaml:"items" toml:"items"`
+	Network         string            `json:"network" yaml:"network" toml:"network"`
+	Header          bool              `json:"header" yaml:"header" toml:"header"` // CSV only.
+	Details         map[string]string `json:"details" yaml:"details" toml:"details"`
+	Replace         bool              `json:"replace" yaml:"replace" toml:"replace"`
+}
+
+// LoadConfig reads a YAML, JSON or TOML config file, depending on its extension.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	var config Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to decode yaml: %v", err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to decode json: %v", err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), &config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode toml: %v", err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("failed to decode toml: unknown field '%s'", undecoded[0])
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension: %s", filepath.Ext(path))
+	}
+
+	return &config, nil
+}
+
+// RegisterConfig loads the config file and registers all its sources.
+func RegisterConfig(path string) error {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	for _, sc := range config.Sources {
+		src, err := sc.IPSource()
+		if err != nil {
+			return err
+		}
+		var replaced *IPSource
+		if sc.Replace {
+			replaced = replaceSource(sc, src)
+		}
+		if err := Register(sc.Key, src); err != nil {
+			if replaced != nil {
+				// Put the original back, it was registered under the same key.
+				Register(sc.Key, replaced)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceSource unregisters the source with the key of sc and copies its name,
+// description and categories to src unless sc sets them.
+// It returns the unregistered source, nil if there was none.
+func replaceSource(sc SourceConfig, src *IPSource) *IPSource {
+	replaced, ok := Registered()[sc.Key]
+	if !ok {
+		return nil
+	}
+	Unregister(sc.Key)
+
+	if sc.Name == "" {
+		src.Name = replaced.Name
+	}
+	if sc.Description == "" {
+		src.Description = replaced.Description
+	}
+	if len(sc.Categories) == 0 {
+		src.Categories = replaced.Categories
+	}
+	return replaced
+}
+
+// configKeyRe matches the valid keys of the config sources, which are also used
+// in the name of their data file.
+var configKeyRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// IPSource validates the config and returns the source it defines.
+// Sources read from a local file are not saved to the data directory.
+func (sc SourceConfig) IPSource() (*IPSource, error) {
+	if sc.Key == "" {
+		return nil, errors.New("source without key")
+	}
+	if !configKeyRe.MatchString(sc.Key) {
+		return nil, fmt.Errorf("source '%s': invalid key, it must be lowercase letters, digits, '.', '_' or '-'", sc.Key)
+	}
+	if (sc.URL == "") == (sc.Path == "") {
+		return nil, fmt.Errorf("source '%s': exactly one of url or path must be set", sc.Key)
+	}
+
+	var (
+		parser           ParserFunc
+		prefixCategories []Category
+	)
+	switch sc.Format {
+	case FormatJSON:
+		if sc.Network == "" {
+			return nil, fmt.Errorf("source '%s': network is required", sc.Key)
+		}
+		parser = JSONParser{Items: sc.Items, Network: strings.Split(sc.Network, "|"), Details: sc.Details}.Parse
+	case FormatCSV:
+		if sc.Network == "" {
+			return nil, fmt.Errorf("source '%s': network is required", sc.Key)
+		}
+		parser = CSVParser{Header: sc.Header, Network: sc.Network, Details: sc.Details}.Parse
+	case FormatText:
+		parser = parseText
+	case FormatGeofeed:
+		parser = geofeedParser.Parse
+	case FormatAzure:
+		parser = parseAzure
+		prefixCategories = overrideCategories(azureServiceCategories)
+	default:
+		return nil, fmt.Errorf("source '%s': unknown format '%s'", sc.Key, sc.Format)
+	}
+
+	var categories []Category
+	for _, id := range sc.Categories {
+		cat, ok := Categories[id]
+		if !ok {
+			return nil, fmt.Errorf("source '%s': unknown category '%s'", sc.Key, id)
+		}
+		categories = append(categories, cat)
+	}
+
+	refreshInterval := defaultConfigRefreshInterval
+	if sc.RefreshInterval != "" {
+		d, err := time.ParseDuration(sc.RefreshInterval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("source '%s': invalid refresh interval '%s'", sc.Key, sc.RefreshInterval)
+		}
+		refreshInterval = d
+	}
+
+	var maxStaleAge time.Duration
+	if sc.MaxStaleAge != "" {
+		d, err := time.ParseDuration(sc.MaxStaleAge)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("source '%s': invalid max stale age '%s'", sc.Key, sc.MaxStaleAge)
+		}
+		maxStaleAge = d
+	}
+
+	src := &IPSource{
+		URL:              sc.URL,
+		Name:             sc.Name,
+		Description:      sc.Description,
+		Categories:       categories,
+		PrefixCategories: prefixCategories,
+		RefreshInterval:  refreshInterval,
+		MaxStaleAge:      maxStaleAge,
+		Source:           parser,
+	}
+	if src.Name == "" {
+		src.Name = sc.Key
+	}
+	if sc.Path != "" {
+		src.URL = sc.Path
+		src.Source = fileSource{parser}
+	} else {
+		src.DataFilename = "config-" + sc.Key + ".bin"
+	}
+	return src, nil
+}
+
+// fileSource is a Source that reads a local file.
+type fileSource struct {
+	ParserFunc
+}
+
+// Fetch opens the file.
+func (f fileSource) Fetch(ctx context.Context, path string, cached Validators) (io.ReadCloser, Validators, error) {
+	return fetchFile(path, cached)
+}
@@ -0,0 +1,287 @@
+package sources
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// networksOf returns the networks of the prefixes as strings.
+func networksOf(prefixes []Prefix) []string {
+	var networks []string
+	for _, p := range prefixes {
+		networks = append(networks, p.Network.String())
+	}
+	return networks
+}
+
+func TestLoadConfig(t *testing.T) {
+	configs := map[string]string{
+		"sources.yaml": `
+sources:
+  - key: office
+    name: Office
+    path: /etc/whoip/office.txt
+    format: text
+    categories: [business]
+    refresh_interval: 1h
+`,
+		"sources.json": `{"sources": [{"key": "office", "name": "Office", "path": "/etc/whoip/office.txt",
+			"format": "text", "categories": ["business"], "refresh_interval": "1h"}]}`,
+		"sources.toml": `
+[[sources]]
+key = "office"
+name = "Office"
+path = "/etc/whoip/office.txt"
+format = "text"
+categories = ["business"]
+refresh_interval = "1h"
+`,
+	}
+
+	expected := SourceConfig{
+		Key:             "office",
+		Name:            "Office",
+		Path:            "/etc/whoip/office.txt",
+		Format:          FormatText,
+		Categories:      []string{"business"},
+		RefreshInterval: "1h",
+	}
+
+	dir := t.TempDir()
+	for filename, content := range configs {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(dir, filename)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("Failed to load config: %v", err)
+			}
+			if len(config.Sources) != 1 || !reflect.DeepEqual(config.Sources[0], expected) {
+				t.Errorf("Wrong config. Expected: %+v, Got: %+v", expected, config.Sources)
+			}
+		})
+	}
+
+	path := filepath.Join(dir, "unknown.yaml")
+	if err := os.WriteFile(path, []byte("sources:\n  - key: office\n    typo: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Errorf("Expected an error loading a config with unknown fields")
+	}
+}
+
+func TestSourceConfigValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SourceConfig
+	}{
+		{"no key", SourceConfig{Path: "ranges.txt", Format: FormatText}},
+		{"key with path", SourceConfig{Key: "../fake", Path: "ranges.txt", Format: FormatText}},
+		{"uppercase key", SourceConfig{Key: "Fake", Path: "ranges.txt", Format: FormatText}},
+		{"no url or path", SourceConfig{Key: "fake", Format: FormatText}},
+		{"url and path", SourceConfig{Key: "fake", URL: "https://www.example.com/", Path: "ranges.txt", Format: FormatText}},
+		{"unknown format", SourceConfig{Key: "fake", Path: "ranges.txt", Format: "xml"}},
+		{"no network", SourceConfig{Key: "fake", Path: "ranges.json", Format: FormatJSON}},
+		{"unknown category", SourceConfig{Key: "fake", Path: "ranges.txt", Format: FormatText, Categories: []string{"typo"}}},
+		{"invalid refresh interval", SourceConfig{Key: "fake", Path: "ranges.txt", Format: FormatText, RefreshInterval: "1 day"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := tt.config.IPSource(); err == nil {
+			t.Errorf("Expected an error for a config with %s", tt.name)
+		}
+	}
+}
+
+func TestConfigFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "office.txt")
+	if err := os.WriteFile(path, []byte("# Office\n192.0.2.0/24\n\n2001:db8::1 # VPN\ninvalid\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := SourceConfig{Key: "office", Path: path, Format: FormatText}.IPSource()
+	if err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	if src.Name != "office" || src.RefreshInterval != 24*time.Hour || src.DataFilename != "" {
+		t.Errorf("Wrong defaults: %+v", src)
+	}
+
+	if err := src.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh source: %v", err)
+	}
+
+	expected := []string{"192.0.2.0/24", "2001:db8::1/128"}
+	if got := networksOf(src.Snapshot().Prefixes); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong prefixes. Expected: %v, Got: %v", expected, got)
+	}
+}
+
+func TestJSONParser(t *testing.T) {
+	data := `{"values": [
+		{"name": "Storage", "properties": {"region": "westeurope", "addressPrefixes": ["20.38.0.0/16", "2603:1020::/47"]}},
+		{"name": "Global", "properties": {"addressPrefixes": ["13.64.0.0/11"]}},
+		{"name": "Google", "ipv6Prefix": "2600:1900::/28"}
+	]}`
+
+	parser := JSONParser{
+		Items:   "values",
+		Network: []string{"properties.addressPrefixes", "ipv4Prefix", "ipv6Prefix"},
+		Details: map[string]string{"Service": "name", "Region": "properties.region"},
+	}
+	parsed, err := parser.Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse data: %v", err)
+	}
+	prefixes := parsed.Prefixes
+
+	expected := []string{"20.38.0.0/16", "2603:1020::/47", "13.64.0.0/11", "2600:1900::/28"}
+	if got := networksOf(prefixes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Wrong prefixes. Expected: %v, Got: %v", expected, got)
+	}
+
+	details := []map[string]string{
+		{"Service": "Storage", "Region": "westeurope"},
+		{"Service": "Storage", "Region": "westeurope"},
+		{"Service": "Global"},
+		{"Service": "Google"},
+	}
+	for i, p := range prefixes {
+		if !reflect.DeepEqual(p.Details, details[i]) {
+			t.Errorf("Wrong details for '%s'. Expected: %v, Got: %v", p.Network.String(), details[i], p.Details)
+		}
+	}
+}
+
+func TestCSVParser(t *testing.T) {
+	geofeed := "# prefix,country,region,city,postal\n5.101.96.0/21,NL,NL-NH,Amsterdam,\n2a03:b0c0::/32,US,US-NY,New York,\n"
+	data, err := CSVParser{Network: "0", Details: map[string]string{"Country": "1", "City": "3", "Postal": "4"}}.Parse(strings.NewReader(geofeed))
+	if err != nil {
+		t.Fatalf("Failed to parse data: %v", err)
+	}
+	prefixes := data.Prefixes
+	if got := networksOf(prefixes); !reflect.DeepEqual(got, []string{"5.101.96.0/21", "2a03:b0c0::/32"}) {
+		t.Errorf("Wrong prefixes: %v", got)
+	}
+	if expected := map[string]string{"Country": "NL", "City": "Amsterdam"}; !reflect.DeepEqual(prefixes[0].Details, expected) {
+		t.Errorf("Wrong details. Expected: %v, Got: %v", expected, prefixes[0].Details)
+	}
+
+	withHeader := "cidr,owner\n198.51.100.0/24,partner\n"
+	data, err = CSVParser{Header: true, Network: "cidr", Details: map[string]string{"Owner": "owner"}}.Parse(strings.NewReader(withHeader))
+	if err != nil {
+		t.Fatalf("Failed to parse data: %v", err)
+	}
+	prefixes = data.Prefixes
+	if len(prefixes) != 1 || prefixes[0].Details["Owner"] != "partner" {
+		t.Errorf("Wrong prefixes: %v", prefixes)
+	}
+
+	if _, err := (CSVParser{Header: true, Network: "missing"}).Parse(strings.NewReader(withHeader)); err == nil {
+		t.Errorf("Expected an error for an unknown column")
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.0/24":  "192.0.2.0/24",
+		" 192.0.2.1 ":   "192.0.2.1/32",
+		"2001:db8::/32": "2001:db8::/32",
+		"2001:db8::1":   "2001:db8::1/128",
+		"192.0.2.1/33":  "",
+		"":              "",
+	}
+	for input, expected := range tests {
+		network, ok := parseNetwork(input)
+		got := ""
+		if ok {
+			got = network.String()
+		}
+		if got != expected {
+			t.Errorf("Wrong network for '%s'. Expected: %s, Got: %s", input, expected, got)
+		}
+	}
+
+	if network, _ := parseNetwork("192.0.2.1"); len(network.IP) != net.IPv4len {
+		t.Errorf("Expected a 4-byte IPv4 address, got %d bytes", len(network.IP))
+	}
+}
+
+func TestRegisterConfigReplace(t *testing.T) {
+	original := &IPSource{
+		Name:        "Fake Replaced Source",
+		Description: "A fake source replaced by a config",
+		Categories:  []Category{Categories["datacenter"]},
+		Source:      fakeSource{},
+	}
+	if err := Register("fake-replaced", original); err != nil {
+		t.Fatalf("Failed to register source: %v", err)
+	}
+	defer Unregister("fake-replaced")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sources.yaml")
+	config := "sources:\n  - key: fake-replaced\n    path: " + filepath.Join(dir, "tags.json") + "\n    format: azure\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterConfig(path); err == nil {
+		t.Errorf("Expected an error registering a duplicate key")
+	}
+
+	if err := os.WriteFile(path, []byte(config+"    replace: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterConfig(path); err != nil {
+		t.Fatalf("Failed to register config: %v", err)
+	}
+
+	src := Registered()["fake-replaced"]
+	if src == original {
+		t.Fatalf("Source not replaced")
+	}
+	if src.Name != original.Name || src.Description != original.Description || !reflect.DeepEqual(src.Categories, original.Categories) {
+		t.Errorf("Wrong replaced source: %+v", src)
+	}
+	if _, ok := src.Source.(fileSource); !ok {
+		t.Errorf("Wrong Source for a local file: %T", src.Source)
+	}
+}
+
+func TestRegisterConfigReplaceFailure(t *testing.T) {
+	original := &IPSource{Name: "Fake Kept Source", Source: fakeSource{}}
+	if err := Register("fake-kept", original); err != nil {
+		t.Fatalf("Failed to register source: %v", err)
+	}
+	defer Unregister("fake-kept")
+
+	// The data file of the replacement is taken, so it can not be registered.
+	other := &IPSource{Name: "Fake Other Source", DataFilename: "config-fake-kept.bin", Source: fakeSource{}}
+	if err := Register("fake-other", other); err != nil {
+		t.Fatalf("Failed to register source: %v", err)
+	}
+	defer Unregister("fake-other")
+
+	path := filepath.Join(t.TempDir(), "sources.yaml")
+	config := "sources:\n  - key: fake-kept\n    url: https://www.example.com/ranges.txt\n    format: text\n    replace: true\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterConfig(path); err == nil {
+		t.Fatalf("Expected an error registering a source with a taken data file")
+	}
+
+	if src := Registered()["fake-kept"]; src != original {
+		t.Errorf("Original source not kept after a failed replace: %+v", src)
+	}
+}
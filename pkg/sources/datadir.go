@@ -0,0 +1,71 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	utils "github.com/aorith/whoip/internal"
+)
+
+var (
+	dataDirMu      sync.Mutex
+	dataDir        string
+	dataDirSet     bool // The directory was set with SetDataDirectory or already resolved.
+	dataDirCreated bool
+)
+
+// SetDataDirectory sets the directory where the sources save their data, instead of
+// $WHOIP_DATA_DIR or the default locations. An empty directory enables the in-memory
+// mode, where the data is never read from nor saved to disk.
+// It must be called before the sources are refreshed.
+func SetDataDirectory(dir string) {
+	dataDirMu.Lock()
+	defer dataDirMu.Unlock()
+
+	dataDir, dataDirSet, dataDirCreated = dir, true, false
+}
+
+// DataDirectory returns the directory where the sources save their data, creating
+// it on first use. It returns an empty string in the in-memory mode.
+func DataDirectory() (string, error) {
+	dataDirMu.Lock()
+	defer dataDirMu.Unlock()
+
+	if !dataDirSet {
+		dir, err := utils.GetDataDirectory()
+		if err != nil {
+			return "", err
+		}
+		dataDir, dataDirSet, dataDirCreated = dir, true, true
+	}
+
+	if dataDir != "" && !dataDirCreated {
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create data directory: %v", err)
+		}
+		dataDirCreated = true
+	}
+	return dataDir, nil
+}
+
+// dataPath returns the path of the data file of the source.
+// It returns an empty string if the data of the source is kept in memory only.
+func (src *IPSource) dataPath() (string, error) {
+	if src.DataFilename == "" {
+		return "", nil
+	}
+
+	dir, err := DataDirectory()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %v", err)
+	}
+	if dir == "" {
+		return "", nil
+	}
+	if filepath.IsAbs(src.DataFilename) {
+		return src.DataFilename, nil
+	}
+	return filepath.Join(dir, src.DataFilename), nil
+}
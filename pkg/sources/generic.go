@@ -0,0 +1,218 @@
+package sources
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// JSONParser parses JSON documents with a list of prefixes.
+// Paths are dot separated keys, lists found along a path are traversed.
+type JSONParser struct {
+	Items   string            // Path to the list of prefixes, empty for the root of the document.
+	Network []string          // Paths of the prefix in each item, the first one found is used.
+	Details map[string]string // Path of each detail in each item.
+}
+
+// Parse parses the JSON document.
+func (p JSONParser) Parse(r io.Reader) (*Data, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode json: %v", err)
+	}
+
+	var prefixes []Prefix
+	for _, item := range jsonPath(doc, p.Items) {
+		var networks []any
+		for _, path := range p.Network {
+			if networks = jsonPath(item, path); len(networks) > 0 {
+				break
+			}
+		}
+
+		var details map[string]string
+		for name, path := range p.Details {
+			values := jsonPath(item, path)
+			if len(values) == 0 {
+				continue
+			}
+			if details == nil {
+				details = make(map[string]string, len(p.Details))
+			}
+			details[name] = fmt.Sprint(values[0])
+		}
+
+		for _, v := range networks {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			network, ok := parseNetwork(s)
+			if !ok {
+				continue
+			}
+			prefixes = append(prefixes, Prefix{Network: *network, Details: details})
+		}
+	}
+
+	return &Data{Prefixes: prefixes}, nil
+}
+
+// jsonPath returns the values found at the path, flattening the lists found along it.
+func jsonPath(v any, path string) []any {
+	values := []any{v}
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			var next []any
+			for _, v := range flatten(values) {
+				if m, ok := v.(map[string]any); ok {
+					if child, ok := m[key]; ok {
+						next = append(next, child)
+					}
+				}
+			}
+			values = next
+		}
+	}
+	return flatten(values)
+}
+
+// flatten replaces the lists in values with their items.
+func flatten(values []any) []any {
+	var flat []any
+	for _, v := range values {
+		if list, ok := v.([]any); ok {
+			flat = append(flat, flatten(list)...)
+		} else if v != nil {
+			flat = append(flat, v)
+		}
+	}
+	return flat
+}
+
+// CSVParser parses CSV files with a prefix per row, like RFC 8805 geofeeds.
+// Rows starting with '#' are ignored. Columns are 0-based indexes, or names
+// when the first row is a header.
+type CSVParser struct {
+	Header  bool
+	Network string            // Column of the prefix.
+	Details map[string]string // Column of each detail.
+}
+
+// Parse parses the CSV file.
+func (p CSVParser) Parse(r io.Reader) (*Data, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var header []string
+	if p.Header {
+		row, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv header: %v", err)
+		}
+		header = row
+	}
+
+	networkColumn, err := csvColumn(header, p.Network)
+	if err != nil {
+		return nil, err
+	}
+	detailColumns := make(map[string]int, len(p.Details))
+	for name, column := range p.Details {
+		if detailColumns[name], err = csvColumn(header, column); err != nil {
+			return nil, err
+		}
+	}
+
+	var prefixes []Prefix
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %v", err)
+		}
+		if networkColumn >= len(row) {
+			continue
+		}
+		network, ok := parseNetwork(row[networkColumn])
+		if !ok {
+			continue
+		}
+
+		var details map[string]string
+		for name, column := range detailColumns {
+			if column >= len(row) || row[column] == "" {
+				continue
+			}
+			if details == nil {
+				details = make(map[string]string, len(detailColumns))
+			}
+			details[name] = strings.TrimSpace(row[column])
+		}
+		prefixes = append(prefixes, Prefix{Network: *network, Details: details})
+	}
+
+	return &Data{Prefixes: prefixes}, nil
+}
+
+// csvColumn returns the index of the column, given as a name of the header or as an index.
+func csvColumn(header []string, column string) (int, error) {
+	if i := slices.Index(header, column); i >= 0 {
+		return i, nil
+	}
+	i, err := strconv.Atoi(column)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("unknown csv column: %s", column)
+	}
+	return i, nil
+}
+
+// parseText parses a plain-text list with a prefix or address per line.
+// Blank lines and everything after a '#' are ignored.
+func parseText(r io.Reader) (*Data, error) {
+	var prefixes []Prefix
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		network, ok := parseNetwork(line)
+		if !ok {
+			continue
+		}
+		prefixes = append(prefixes, Prefix{Network: *network})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read data: %v", err)
+	}
+	return &Data{Prefixes: prefixes}, nil
+}
+
+// parseNetwork parses a CIDR, or a single address as a network of one address.
+func parseNetwork(s string) (*net.IPNet, bool) {
+	s = strings.TrimSpace(s)
+	if _, network, err := net.ParseCIDR(s); err == nil {
+		return network, true
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, true
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, true
+}
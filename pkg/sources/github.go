@@ -0,0 +1,58 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// githubServiceCategories sets the categories of the prefixes of the services that
+// run user code, the source has no categories of its own.
+// Webhook deliveries are not crawlers, they are matched by their "hooks" Service detail.
+var githubServiceCategories = map[string][]Category{
+	"actions":       {Categories["datacenter"]}, // Hosted runners.
+	"actions_macos": {Categories["datacenter"]},
+	"codespaces":    {Categories["datacenter"]},
+}
+
+// parseGitHub parses the GitHub meta API response.
+// Every list of prefixes is keyed by the service that uses it, other keys are ignored.
+func parseGitHub(r io.Reader) (*Data, error) {
+	var fetchedData map[string]json.RawMessage
+
+	err := json.NewDecoder(r).Decode(&fetchedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode json: %v", err)
+	}
+
+	services := make([]string, 0, len(fetchedData))
+	for service := range fetchedData {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	var prefixes []Prefix
+	for _, service := range services {
+		var list []string
+		if err := json.Unmarshal(fetchedData[service], &list); err != nil {
+			continue // Not a list of strings.
+		}
+
+		details := map[string]string{"Service": service}
+		for _, s := range list {
+			network, ok := parseNetwork(s)
+			if !ok {
+				continue // Like the SSH keys.
+			}
+			prefixes = append(prefixes, Prefix{
+				Network:    *network,
+				Details:    details,
+				Categories: githubServiceCategories[service],
+			})
+		}
+	}
+
+	// The same prefixes are listed by several services, like hooks, web, api and git.
+	return &Data{Prefixes: mergePrefixes(prefixes, "Service")}, nil
+}
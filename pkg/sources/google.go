@@ -3,35 +3,16 @@ package sources
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
-	"net/http"
 	"time"
 )
 
-// fetchGoogleData fetches the Google data and updates the MetaData.
-func fetchGoogleData(src *IPSource) error {
-	src.Mu.Lock()
-	defer src.Mu.Unlock()
-
-	if time.Since(src.MetaData.LastUpdate) < src.RefreshInterval {
-		return nil // Data is up to date
-	}
-
-	if src.load() {
-		// Data is still valid
-		return nil
-	}
-
-	resp, err := http.Get(src.URL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch data: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("received non-200 response code: %d", resp.StatusCode)
-	}
+// googleTimeLayout is the layout of the creationTime of the Google files, in UTC.
+const googleTimeLayout = "2006-01-02T15:04:05.999999"
 
+// parseGoogle parses the Google Cloud cloud.json file.
+func parseGoogle(r io.Reader) (*Data, error) {
 	var fetchedData struct {
 		SyncToken    string `json:"syncToken"`
 		CreationTime string `json:"creationTime"`
@@ -43,20 +24,16 @@ func fetchGoogleData(src *IPSource) error {
 		} `json:"prefixes"`
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&fetchedData)
+	err := json.NewDecoder(r).Decode(&fetchedData)
 	if err != nil {
-		return fmt.Errorf("failed to decode json: %v", err)
+		return nil, fmt.Errorf("failed to decode json: %v", err)
 	}
 
 	var prefixes []Prefix
 	for _, p := range fetchedData.Prefixes {
-		var network *net.IPNet
-		_, network, err = net.ParseCIDR(p.IPv4Prefix)
-		if err != nil {
-			_, network, err = net.ParseCIDR(p.IPv6Prefix)
-			if err != nil {
-				continue
-			}
+		network, ok := parseIPv4OrIPv6(p.IPv4Prefix, p.IPv6Prefix)
+		if !ok {
+			continue
 		}
 		prefixes = append(prefixes, Prefix{
 			Network: *network,
@@ -67,67 +44,49 @@ func fetchGoogleData(src *IPSource) error {
 		})
 	}
 
-	src.MetaData.Prefixes = prefixes
-	src.MetaData.LastUpdate = time.Now()
-	src.mustSave()
-
-	return nil
+	published, _ := time.Parse(googleTimeLayout, fetchedData.CreationTime)
+	return &Data{Prefixes: prefixes, SyncToken: fetchedData.SyncToken, Published: published}, nil
 }
 
-// fetchGoogleBotData fetches the Google data and updates the MetaData.
-func fetchGoogleBotData(src *IPSource) error {
-	src.Mu.Lock()
-	defer src.Mu.Unlock()
-
-	if time.Since(src.MetaData.LastUpdate) < src.RefreshInterval {
-		return nil // Data is up to date
-	}
-
-	if src.load() {
-		// Data is still valid
-		return nil
-	}
-
-	resp, err := http.Get(src.URL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch data: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("received non-200 response code: %d", resp.StatusCode)
-	}
-
+// parsePrefixList parses the prefix lists in the format published by Google for
+// its crawlers, also used by Bing, Apple, OpenAI and other crawlers.
+func parsePrefixList(r io.Reader) (*Data, error) {
 	var fetchedData struct {
-		Prefixes []struct {
+		CreationTime string `json:"creationTime"`
+		Prefixes     []struct {
 			IPv4Prefix string `json:"ipv4Prefix,omitempty"`
 			IPv6Prefix string `json:"ipv6Prefix,omitempty"`
 		} `json:"prefixes"`
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&fetchedData)
+	err := json.NewDecoder(r).Decode(&fetchedData)
 	if err != nil {
-		return fmt.Errorf("failed to decode json: %v", err)
+		return nil, fmt.Errorf("failed to decode json: %v", err)
 	}
 
 	var prefixes []Prefix
 	for _, p := range fetchedData.Prefixes {
-		var network *net.IPNet
-		_, network, err = net.ParseCIDR(p.IPv4Prefix)
-		if err != nil {
-			_, network, err = net.ParseCIDR(p.IPv6Prefix)
-			if err != nil {
-				continue
-			}
+		network, ok := parseIPv4OrIPv6(p.IPv4Prefix, p.IPv6Prefix)
+		if !ok {
+			continue
 		}
 		prefixes = append(prefixes, Prefix{
 			Network: *network,
 		})
 	}
 
-	src.MetaData.Prefixes = prefixes
-	src.MetaData.LastUpdate = time.Now()
-	src.mustSave()
+	published, _ := time.Parse(googleTimeLayout, fetchedData.CreationTime)
+	return &Data{Prefixes: prefixes, Published: published}, nil
+}
 
-	return nil
+// parseIPv4OrIPv6 parses the IPv4 prefix, or the IPv6 prefix if the former is not valid.
+func parseIPv4OrIPv6(ipv4Prefix, ipv6Prefix string) (*net.IPNet, bool) {
+	_, network, err := net.ParseCIDR(ipv4Prefix)
+	if err != nil {
+		_, network, err = net.ParseCIDR(ipv6Prefix)
+		if err != nil {
+			return nil, false
+		}
+	}
+	return network, true
 }
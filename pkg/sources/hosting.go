@@ -0,0 +1,57 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// geofeedParser parses RFC 8805 geofeeds: prefix, country, region, city and postal code.
+var geofeedParser = CSVParser{
+	Network: "0",
+	Details: map[string]string{"Country": "1", "Region": "2", "City": "3", "Postal": "4"},
+}
+
+// oracleTimeLayout is the layout of the last_updated_timestamp of the Oracle Cloud file, in UTC.
+const oracleTimeLayout = "2006-01-02T15:04:05.999999"
+
+// parseOracle parses the Oracle Cloud public_ip_ranges.json file.
+func parseOracle(r io.Reader) (*Data, error) {
+	var fetchedData struct {
+		LastUpdated string `json:"last_updated_timestamp"`
+		Regions     []struct {
+			Region string `json:"region"`
+			CIDRs  []struct {
+				CIDR string   `json:"cidr"`
+				Tags []string `json:"tags"`
+			} `json:"cidrs"`
+		} `json:"regions"`
+	}
+
+	err := json.NewDecoder(r).Decode(&fetchedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode json: %v", err)
+	}
+
+	var prefixes []Prefix
+	for _, region := range fetchedData.Regions {
+		for _, c := range region.CIDRs {
+			network, ok := parseNetwork(c.CIDR)
+			if !ok {
+				continue
+			}
+			prefixes = append(prefixes, Prefix{
+				Network: *network,
+				Details: map[string]string{
+					"Region": region.Region,
+					"Tags":   strings.Join(c.Tags, ","),
+				},
+			})
+		}
+	}
+
+	published, _ := time.Parse(oracleTimeLayout, fetchedData.LastUpdated)
+	return &Data{Prefixes: prefixes, Published: published}, nil
+}
@@ -0,0 +1,170 @@
+package sources
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	utils "github.com/aorith/whoip/internal"
+)
+
+// HTTPOptions configures the HTTP client used to download the data of the sources.
+type HTTPOptions struct {
+	Timeout    time.Duration // Timeout of every attempt, including reading the response.
+	Retries    int           // Retries after network errors and 5xx responses.
+	RetryDelay time.Duration // Delay before the first retry, doubled after every retry.
+	Proxy      string        // Proxy URL, the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables are used if empty.
+	CAFile     string        // PEM file with CA certificates trusted besides the system ones.
+	UserAgent  string        // User-Agent header, DefaultUserAgent if empty.
+}
+
+// DefaultHTTPOptions returns the options used unless SetHTTPOptions is called.
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		Timeout:    60 * time.Second,
+		Retries:    3,
+		RetryDelay: 1 * time.Second,
+	}
+}
+
+// DefaultUserAgent returns the User-Agent sent when none is configured.
+func DefaultUserAgent() string {
+	return fmt.Sprintf("whoip/%s (+https://github.com/aorith/whoip)", utils.Version())
+}
+
+// httpFetcher is an HTTP client with its options.
+type httpFetcher struct {
+	client *http.Client
+	opts   HTTPOptions
+}
+
+// currentFetcher is the fetcher used by fetchURL.
+var currentFetcher atomic.Pointer[httpFetcher]
+
+func init() {
+	f, _ := newHTTPFetcher(DefaultHTTPOptions())
+	currentFetcher.Store(f)
+}
+
+// SetHTTPOptions configures the HTTP client used by the sources that download their data.
+func SetHTTPOptions(opts HTTPOptions) error {
+	f, err := newHTTPFetcher(opts)
+	if err != nil {
+		return err
+	}
+	currentFetcher.Store(f)
+	return nil
+}
+
+// newHTTPFetcher returns a fetcher with a client built from the options.
+func newHTTPFetcher(opts HTTPOptions) (*httpFetcher, error) {
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent()
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL: %s", opts.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file: %s", opts.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &httpFetcher{client: &http.Client{Transport: transport}, opts: opts}, nil
+}
+
+// cancelBody cancels the context of the request when the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// fetch sends a GET request with the headers, retrying on network errors and 5xx responses.
+// The response body must be read before its Close, which releases the timeout of the attempt.
+func (f *httpFetcher) fetch(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	delay := f.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := f.do(ctx, url, header)
+		retry := err != nil || resp.StatusCode >= 500
+		if !retry || attempt >= f.opts.Retries || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to fetch data: %v", ctx.Err())
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// do sends a single attempt of the request.
+func (f *httpFetcher) do(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	var (
+		attemptCtx context.Context
+		cancel     context.CancelFunc
+	)
+	if f.opts.Timeout > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, f.opts.Timeout)
+	} else {
+		attemptCtx, cancel = context.WithCancel(ctx)
+	}
+
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", f.opts.UserAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, fmt.Errorf("failed to fetch data: timeout after %s", f.opts.Timeout)
+		}
+		return nil, fmt.Errorf("failed to fetch data: %v", err)
+	}
+	resp.Body = cancelBody{resp.Body, cancel}
+	return resp, nil
+}
@@ -0,0 +1,121 @@
+package sources
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFetch downloads the URL with a fetcher built from the options.
+func testFetch(t *testing.T, opts HTTPOptions, url string) (string, error) {
+	t.Helper()
+
+	f, err := newHTTPFetcher(opts)
+	if err != nil {
+		t.Fatalf("Failed to create fetcher: %v", err)
+	}
+	resp, err := f.fetch(context.Background(), url, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestFetchRetries(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(r.Header.Get("User-Agent")))
+	}))
+	defer server.Close()
+
+	body, err := testFetch(t, HTTPOptions{Retries: 2, RetryDelay: time.Millisecond}, server.URL)
+	if err != nil {
+		t.Fatalf("Failed to fetch data: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Wrong number of requests. Expected: 3, Got: %d", requests)
+	}
+	if body != DefaultUserAgent() {
+		t.Errorf("Wrong User-Agent. Expected: %s, Got: %s", DefaultUserAgent(), body)
+	}
+
+	requests = 0
+	f, _ := newHTTPFetcher(HTTPOptions{Retries: 1, RetryDelay: time.Millisecond})
+	resp, err := f.fetch(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to fetch data: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || requests != 2 {
+		t.Errorf("Expected the last 5xx response after 2 requests, got %d after %d requests", resp.StatusCode, requests)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	_, err := testFetch(t, HTTPOptions{Timeout: 50 * time.Millisecond}, server.URL)
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected a timeout error, got: %v", err)
+	}
+}
+
+func TestFetchProxyAndCA(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied " + r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	body, err := testFetch(t, HTTPOptions{Proxy: proxy.URL}, "http://ranges.example.com/ranges.txt")
+	if err != nil {
+		t.Fatalf("Failed to fetch data: %v", err)
+	}
+	if body != "proxied http://ranges.example.com/ranges.txt" {
+		t.Errorf("Request not sent through the proxy: %s", body)
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	if _, err := testFetch(t, HTTPOptions{}, server.URL); err == nil {
+		t.Errorf("Expected an error fetching from a server with an unknown CA")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if body, err := testFetch(t, HTTPOptions{CAFile: caFile}, server.URL); err != nil || body != "ok" {
+		t.Errorf("Failed to fetch data with the extra CA: %v", err)
+	}
+
+	if _, err := newHTTPFetcher(HTTPOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Errorf("Expected an error for a missing CA file")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package sources
+
+import "context"
+
+// lockFile does nothing on platforms without flock, concurrent processes may
+// download the same data but writes are still atomic.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lockRetryInterval is how often a lock held by another process is tried again.
+const lockRetryInterval = 100 * time.Millisecond
+
+// lockFile takes an exclusive advisory lock on the lock file of the data file at path,
+// waiting until other processes release it or ctx is done. The returned function
+// releases the lock.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	file, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock data file: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, fmt.Errorf("failed to lock data file: %v", ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,59 @@
+//go:build unix
+
+package sources
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fake.bin")
+
+	unlock, err := lockFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Failed to lock file: %v", err)
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		unlock, err := lockFile(context.Background(), path)
+		if err != nil {
+			t.Errorf("Failed to lock file: %v", err)
+		} else {
+			unlock()
+		}
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatalf("Lock acquired while held by another owner")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Lock not acquired after being released")
+	}
+}
+
+func TestLockFileContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fake.bin")
+
+	unlock, err := lockFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Failed to lock file: %v", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := lockFile(ctx, path); err == nil {
+		t.Fatalf("Lock acquired while held by another owner")
+	}
+}
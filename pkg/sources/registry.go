@@ -0,0 +1,57 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// registryMu guards IPRangeSources.
+var registryMu sync.RWMutex
+
+// Register adds a source to IPRangeSources under the given key, so it is used by
+// the lookups that do not set their own sources.
+// It returns an error if the key or the data file are already registered.
+func Register(key string, src *IPSource) error {
+	if key == "" {
+		return errors.New("empty source key")
+	}
+	if src == nil || src.Source == nil {
+		return fmt.Errorf("source '%s' has no Source", key)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := IPRangeSources[key]; ok {
+		return fmt.Errorf("source '%s' is already registered", key)
+	}
+	for other, registered := range IPRangeSources {
+		if src.DataFilename != "" && registered.DataFilename == src.DataFilename {
+			return fmt.Errorf("source '%s' uses the data file of the source '%s'", key, other)
+		}
+	}
+	IPRangeSources[key] = src
+	return nil
+}
+
+// Unregister removes the source with the given key from IPRangeSources.
+func Unregister(key string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(IPRangeSources, key)
+}
+
+// Registered returns a copy of IPRangeSources that is safe to use while other
+// sources are registered or unregistered.
+func Registered() map[string]*IPSource {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	srcs := make(map[string]*IPSource, len(IPRangeSources))
+	for key, src := range IPRangeSources {
+		srcs[key] = src
+	}
+	return srcs
+}
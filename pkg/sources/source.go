@@ -0,0 +1,191 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Source is a provider of IP ranges.
+// The caching, persistence and freshness of the data is handled by IPSource,
+// a Source only knows how to get and read the data of its provider.
+type Source interface {
+	// Fetch returns the raw data published at the URL of the source and its validators.
+	// It returns ErrNotModified if the data did not change since the given validators.
+	Fetch(ctx context.Context, url string, cached Validators) (io.ReadCloser, Validators, error)
+	// Parse parses the raw data returned by Fetch.
+	Parse(r io.Reader) (*Data, error)
+}
+
+// Validators identify a version of the raw data of a source, like the HTTP
+// ETag and Last-Modified headers.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// Data is the parsed data of a source.
+type Data struct {
+	Prefixes  []Prefix
+	SyncToken string    // Version of the data set by the provider, if any.
+	Published time.Time // Publication time of the data by the provider, if known.
+}
+
+// ErrNotModified is returned by Fetch when the data did not change.
+var ErrNotModified = errors.New("not modified")
+
+// ParserFunc is a Source that downloads the URL of the source with a conditional
+// HTTP GET request and parses the response with the function.
+type ParserFunc func(r io.Reader) (*Data, error)
+
+// Fetch downloads the URL.
+func (f ParserFunc) Fetch(ctx context.Context, url string, cached Validators) (io.ReadCloser, Validators, error) {
+	return fetchURL(ctx, url, cached)
+}
+
+// Parse calls f(r).
+func (f ParserFunc) Parse(r io.Reader) (*Data, error) {
+	return f(r)
+}
+
+// fetchURL downloads the URL and returns the body of the response and its validators.
+func fetchURL(ctx context.Context, url string, cached Validators) (io.ReadCloser, Validators, error) {
+	header := make(http.Header)
+	if cached.ETag != "" {
+		header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" {
+		header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	resp, err := currentFetcher.Load().fetch(ctx, url, header)
+	if err != nil {
+		return nil, Validators{}, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return nil, cached, ErrNotModified
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, Validators{}, fmt.Errorf("received non-200 response code: %d", resp.StatusCode)
+	}
+
+	validators := Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return resp.Body, validators, nil
+}
+
+// fetchFile opens the local file, unless it was not modified since the validators.
+// The modification time of the file is used as its Last-Modified validator.
+func fetchFile(path string, cached Validators) (io.ReadCloser, Validators, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, Validators{}, fmt.Errorf("failed to open file: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, Validators{}, fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	validators := Validators{LastModified: info.ModTime().UTC().Format(http.TimeFormat)}
+	if validators.LastModified == cached.LastModified {
+		file.Close()
+		return nil, cached, ErrNotModified
+	}
+	return file, validators, nil
+}
+
+// errNoPrefixes is returned when a source is parsed without errors but contains no prefixes.
+var errNoPrefixes = errors.New("no prefixes found")
+
+// update fetches and parses the data of the source and saves it, unless the
+// current data or the data saved by a previous run is still fresh.
+// If it fails, data older than the max stale age of the source is dropped.
+func (src *IPSource) update(ctx context.Context) (err error) {
+	src.Mu.Lock()
+	defer src.Mu.Unlock()
+
+	defer func() {
+		if err != nil && time.Since(src.MetaData.LastUpdate) > src.maxStaleAge() {
+			src.MetaData = IPMetaData{}
+		}
+	}()
+
+	if time.Since(src.MetaData.LastUpdate) < src.RefreshInterval {
+		return nil // Data is up to date
+	}
+
+	path, err := src.dataPath()
+	if err != nil {
+		return err
+	}
+
+	if path != "" {
+		// Other processes sharing the data directory wait while this one downloads the data.
+		unlock, err := lockFile(ctx, path)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if src.load(path) {
+			// Data is still valid
+			return nil
+		}
+	}
+
+	// Without data a conditional request could only return data we no longer have.
+	var cached Validators
+	if len(src.MetaData.Prefixes) > 0 {
+		cached = Validators{ETag: src.MetaData.ETag, LastModified: src.MetaData.LastModified}
+	}
+
+	body, validators, err := src.Source.Fetch(ctx, src.URL, cached)
+	switch {
+	case errors.Is(err, ErrNotModified) && len(src.MetaData.Prefixes) > 0:
+		// The current data is still the published one.
+	case err != nil:
+		return err
+	default:
+		defer body.Close()
+
+		data, err := src.Source.Parse(body)
+		if err != nil {
+			return fmt.Errorf("failed to parse data: %v", err)
+		}
+		if len(data.Prefixes) == 0 {
+			return errNoPrefixes
+		}
+
+		published := data.Published
+		if published.IsZero() {
+			published, _ = http.ParseTime(validators.LastModified)
+		}
+
+		src.MetaData.Prefixes = data.Prefixes
+		src.MetaData.SyncToken = data.SyncToken
+		src.MetaData.Published = published
+	}
+
+	src.MetaData.ETag = validators.ETag
+	src.MetaData.LastModified = validators.LastModified
+	src.MetaData.LastUpdate = time.Now()
+	if path != "" {
+		if err := src.save(path); err != nil {
+			return fmt.Errorf("failed to save data for '%s': %v", src.Name, err)
+		}
+	}
+
+	return nil
+}
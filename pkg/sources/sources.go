@@ -1,26 +1,32 @@
 package sources
 
 import (
+	"context"
 	"encoding/gob"
+	"fmt"
 	"log"
 	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	utils "github.com/aorith/whoip/internal"
 )
 
 // Category represents the type of a category.
 type Category struct {
-	ID          string `json:"id"`
-	Description string `json:"description"`
+	ID          string `json:"id" yaml:"id"`
+	Description string `json:"description" yaml:"description"`
 }
 
 // Predefined Category map.
 var Categories = map[string]Category{
 	"crawler":     {"crawler", "IP ranges used by web crawlers and bots"},
+	"ai":          {"ai", "IP ranges used by AI crawlers and assistants"},
 	"residential": {"residential", "IP ranges assigned to residential users by ISPs"},
 	"business":    {"business", "IP ranges assigned to businesses"},
 	"mobile":      {"mobile", "IP ranges used by mobile carriers for their data services"},
@@ -43,21 +49,41 @@ var Categories = map[string]Category{
 
 // IPSource holds the data for a specific IP ranges source.
 type IPSource struct {
-	URL             string
-	Name            string
-	Description     string
-	Categories      []Category
-	DataFilename    string
-	RefreshInterval time.Duration
-	MetaData        IPMetaData
-	Mu              sync.Mutex
-	Fetcher         func(*IPSource) error
+	URL              string
+	Name             string
+	Description      string
+	Categories       []Category
+	PrefixCategories []Category // Categories that some prefixes use instead of Categories, to select the source by them.
+	DataFilename     string     // Name of the file in the data directory, empty to keep the data in memory only.
+	RefreshInterval  time.Duration
+	MaxStaleAge      time.Duration // How long expired data is still used when refreshing fails, DefaultMaxStaleAge if zero.
+	MetaData         IPMetaData
+	Mu               sync.Mutex
+	Source           Source
+	current          atomic.Pointer[IPMetaData]
+	lastErr          atomic.Pointer[error]
+}
+
+// DefaultMaxStaleAge is how long expired data is still used when refreshing a source fails.
+var DefaultMaxStaleAge = 7 * 24 * time.Hour
+
+// Status is the state of the data of a source after its last refresh.
+type Status struct {
+	LastUpdate time.Time // Zero if the source has no data.
+	Published  time.Time // Publication time of the data by the provider, zero if unknown.
+	SyncToken  string    // Version of the data set by the provider, if any.
+	Stale      bool      // The data expired and refreshing it failed.
+	Error      error     // Error of the last refresh, nil if it succeeded.
 }
 
 // IPMetaData holds the IP ranges for a source.
 type IPMetaData struct {
-	LastUpdate time.Time
-	Prefixes   []Prefix
+	LastUpdate   time.Time
+	Prefixes     []Prefix
+	ETag         string    // HTTP ETag of the data, sent on the next refresh.
+	LastModified string    // HTTP Last-Modified of the data, sent on the next refresh.
+	SyncToken    string    // Version of the data set by the provider, if any.
+	Published    time.Time // Publication time of the data by the provider, zero if unknown.
 }
 
 // Prefix holds the network and details for a prefix.
@@ -67,10 +93,43 @@ type Prefix struct {
 	Categories []Category // Overrides main category.
 }
 
+// NetipPrefix returns the network of the prefix as a netip.Prefix.
+// It returns false if the network is not valid.
+func (p *Prefix) NetipPrefix() (netip.Prefix, bool) {
+	addr, ok := netip.AddrFromSlice(p.Network.IP)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+
+	bits, total := p.Network.Mask.Size()
+	if total == 0 {
+		return netip.Prefix{}, false
+	}
+	if addr.Is4() && total == 128 {
+		bits -= 96
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return prefix, true
+}
+
 // ContainsIP checks if the given IP address is present in the prefixes of the IPSource.
 // If present, it returns the prefix that contains the IP address.
 func (src *IPSource) ContainsIP(ipAddress net.IP) *Prefix {
-	for _, prefix := range src.MetaData.Prefixes {
+	return src.MetaData.ContainsIP(ipAddress)
+}
+
+// ContainsIP checks if the given IP address is present in the prefixes of the metadata.
+// If present, it returns the prefix that contains the IP address.
+func (md *IPMetaData) ContainsIP(ipAddress net.IP) *Prefix {
+	if md == nil {
+		return nil
+	}
+	for _, prefix := range md.Prefixes {
 		if prefix.Network.Contains(ipAddress) {
 			return &prefix
 		}
@@ -78,23 +137,108 @@ func (src *IPSource) ContainsIP(ipAddress net.IP) *Prefix {
 	return nil
 }
 
-// mustSave serializes and saves the metadata to a file.
-func (src *IPSource) mustSave() {
-	file, err := os.Create(src.DataFilename)
+// Refresh updates the data of the source if it is not fresh and publishes the
+// resulting metadata as the current snapshot. On failure the previous data is
+// kept until it is older than the MaxStaleAge of the source.
+// The snapshot is only replaced when the data changed, so users of the snapshot
+// can tell new data apart by its pointer.
+func (src *IPSource) Refresh(ctx context.Context) error {
+	src.Mu.Lock()
+	lastUpdate := src.MetaData.LastUpdate
+	src.Mu.Unlock()
+
+	err := src.update(ctx)
+
+	// Every change of the metadata sets its LastUpdate.
+	src.Mu.Lock()
+	data := src.MetaData
+	src.Mu.Unlock()
+	if !data.LastUpdate.Equal(lastUpdate) || src.current.Load() == nil {
+		src.current.Store(&data)
+	}
+
 	if err != nil {
-		log.Panicf("Failed to create data file at '%s' for '%s': %v", src.DataFilename, src.Name, err)
+		src.lastErr.Store(&err)
+	} else {
+		src.lastErr.Store(nil)
 	}
-	defer file.Close()
+
+	return err
+}
+
+// Status returns the state of the last published snapshot of the source.
+func (src *IPSource) Status() Status {
+	var status Status
+	if errp := src.lastErr.Load(); errp != nil {
+		status.Error = *errp
+	}
+	if data := src.Snapshot(); data != nil && len(data.Prefixes) > 0 {
+		status.LastUpdate = data.LastUpdate
+		status.Published = data.Published
+		status.SyncToken = data.SyncToken
+		status.Stale = status.Error != nil && time.Since(data.LastUpdate) >= src.RefreshInterval
+	}
+	return status
+}
+
+// maxStaleAge returns the MaxStaleAge of the source or DefaultMaxStaleAge if not set.
+func (src *IPSource) maxStaleAge() time.Duration {
+	if src.MaxStaleAge > 0 {
+		return src.MaxStaleAge
+	}
+	return DefaultMaxStaleAge
+}
+
+// Snapshot returns the last published metadata of the source without locking.
+// It returns nil if the source has not been refreshed yet.
+// The returned metadata must not be modified.
+func (src *IPSource) Snapshot() *IPMetaData {
+	return src.current.Load()
+}
+
+// save serializes and saves the metadata to the file at path.
+// The data is written to a temporary file that replaces the previous one once
+// it is synced, so readers never see a partially written file.
+func (src *IPSource) save(path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create data file: %v", err)
+	}
+	defer os.Remove(file.Name()) // Fails once renamed.
 
 	if err := gob.NewEncoder(file).Encode(src.MetaData); err != nil {
-		log.Panicf("Failed to save data at '%s' for '%s': %v", src.DataFilename, src.Name, err)
+		file.Close()
+		return fmt.Errorf("failed to encode data: %v", err)
+	}
+	// CreateTemp uses 0600, data files are shared with other users like before.
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to set data file mode: %v", err)
 	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync data file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close data file: %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename data file: %v", err)
+	}
+
+	// Sync the directory so the rename survives a crash.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
 }
 
-// load deserializes and loads the metadata from a file.
-// returns true if the current data has been replaced false otherwise.
-func (src *IPSource) load() bool {
-	file, err := os.Open(src.DataFilename)
+// load deserializes and loads the metadata from the file at path if it is newer
+// than the current data, so expired data can still be used if refreshing fails.
+// returns true if the loaded data is still fresh false otherwise.
+func (src *IPSource) load(path string) bool {
+	file, err := os.Open(path)
 	if err != nil {
 		return false
 	}
@@ -102,74 +246,296 @@ func (src *IPSource) load() bool {
 
 	var data IPMetaData
 	if err := gob.NewDecoder(file).Decode(&data); err != nil {
-		log.Printf("Failed to decode data file '%s': %v", src.DataFilename, err)
-		if removeErr := os.Remove(src.DataFilename); removeErr != nil {
-			log.Printf("Failed to remove corrupt data file '%s': %v", src.DataFilename, removeErr)
+		log.Printf("Failed to decode data file '%s': %v", path, err)
+		if removeErr := os.Remove(path); removeErr != nil {
+			log.Printf("Failed to remove corrupt data file '%s': %v", path, removeErr)
 		}
 		return false
 	}
 
-	if time.Since(data.LastUpdate) < src.RefreshInterval {
+	if data.LastUpdate.After(src.MetaData.LastUpdate) {
 		src.MetaData = data
-		return true
 	}
-	return false
+	return time.Since(src.MetaData.LastUpdate) < src.RefreshInterval
+}
+
+// overrideCategories returns the categories used by the overrides of a parser, sorted by ID.
+func overrideCategories(overrides map[string][]Category) []Category {
+	var categories []Category
+	for _, cats := range overrides {
+		for _, cat := range cats {
+			if !slices.Contains(categories, cat) {
+				categories = append(categories, cat)
+			}
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].ID < categories[j].ID
+	})
+	return categories
+}
+
+// mergePrefixes merges the prefixes with the same network, for parsers of
+// sources that list a network again under each tag or service that uses it.
+// Merged prefixes keep the position of the first one and the first categories
+// found, which are those of the service overrides as umbrella tags have none.
+// The distinct values of the combine detail keys are joined by commas, other
+// details keep their first value.
+func mergePrefixes(prefixes []Prefix, combine ...string) []Prefix {
+	merged := make([]Prefix, 0, len(prefixes))
+	index := make(map[string]int, len(prefixes))
+	for _, p := range prefixes {
+		key := p.Network.String()
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, p)
+			continue
+		}
+
+		m := &merged[i]
+		if len(m.Categories) == 0 {
+			m.Categories = p.Categories
+		}
+		details := make(map[string]string, len(m.Details))
+		for k, v := range m.Details {
+			details[k] = v
+		}
+		for k, v := range p.Details {
+			switch {
+			case details[k] == "":
+				details[k] = v
+			case slices.Contains(combine, k) && !slices.Contains(strings.Split(details[k], ","), v):
+				details[k] += "," + v
+			}
+		}
+		m.Details = details // Details may be shared by the prefixes of a parser.
+	}
+	return merged
 }
 
-// Predefined IP range sources.
+// IPRangeSources are the registered IP range sources, keyed by a short identifier.
+// It is initialized with the predefined sources, use Register and Unregister to modify it.
 var IPRangeSources = map[string]*IPSource{
 	"aws": {
-		URL:             "https://ip-ranges.amazonaws.com/ip-ranges.json",
-		Name:            "Amazon AWS",
-		Description:     "Amazon AWS IP Ranges",
-		Categories:      []Category{Categories["datacenter"]},
-		DataFilename:    filepath.Join(utils.GetDataDirectory(), "aws.bin"),
-		RefreshInterval: 48 * time.Hour,
-		Fetcher:         fetchAWSData,
+		URL:              "https://ip-ranges.amazonaws.com/ip-ranges.json",
+		Name:             "Amazon AWS",
+		Description:      "Amazon AWS IP Ranges",
+		Categories:       []Category{Categories["datacenter"]},
+		PrefixCategories: overrideCategories(awsServiceCategories),
+		DataFilename:     "aws.bin",
+		RefreshInterval:  48 * time.Hour,
+		Source:           ParserFunc(parseAWS),
 	},
 	"google": {
 		URL:             "https://www.gstatic.com/ipranges/cloud.json",
 		Name:            "Google Cloud",
 		Description:     "Google Cloud IP Ranges",
 		Categories:      []Category{Categories["datacenter"]},
-		DataFilename:    filepath.Join(utils.GetDataDirectory(), "google.bin"),
+		DataFilename:    "google.bin",
+		RefreshInterval: 48 * time.Hour,
+		Source:          ParserFunc(parseGoogle),
+	},
+	"azure": {
+		URL:              azureDownloadURL,
+		Name:             "Microsoft Azure",
+		Description:      "Microsoft Azure Service Tags IP Ranges",
+		Categories:       []Category{Categories["datacenter"]},
+		PrefixCategories: overrideCategories(azureServiceCategories),
+		DataFilename:     "azure.bin",
+		RefreshInterval:  48 * time.Hour,
+		Source:           azureSource{parseAzure},
+	},
+	"oracle": {
+		URL:             "https://docs.oracle.com/en-us/iaas/tools/public_ip_ranges.json",
+		Name:            "Oracle Cloud",
+		Description:     "Oracle Cloud Infrastructure IP Ranges",
+		Categories:      []Category{Categories["datacenter"]},
+		DataFilename:    "oracle.bin",
 		RefreshInterval: 48 * time.Hour,
-		Fetcher:         fetchGoogleData,
+		Source:          ParserFunc(parseOracle),
+	},
+	"digitalocean": {
+		URL:             "https://digitalocean.com/geo/google.csv",
+		Name:            "DigitalOcean",
+		Description:     "DigitalOcean IP Ranges (geofeed)",
+		Categories:      []Category{Categories["datacenter"]},
+		DataFilename:    "digitalocean.bin",
+		RefreshInterval: 48 * time.Hour,
+		Source:          ParserFunc(geofeedParser.Parse),
+	},
+	"linode": {
+		URL:             "https://geoip.linode.com/",
+		Name:            "Linode",
+		Description:     "Linode (Akamai Cloud) IP Ranges (geofeed)",
+		Categories:      []Category{Categories["datacenter"]},
+		DataFilename:    "linode.bin",
+		RefreshInterval: 48 * time.Hour,
+		Source:          ParserFunc(geofeedParser.Parse),
+	},
+	"vultr": {
+		URL:             "https://geofeed.constant.com/?text",
+		Name:            "Vultr",
+		Description:     "Vultr IP Ranges (geofeed)",
+		Categories:      []Category{Categories["datacenter"]},
+		DataFilename:    "vultr.bin",
+		RefreshInterval: 48 * time.Hour,
+		Source:          ParserFunc(geofeedParser.Parse),
+	},
+	"github": {
+		URL:              "https://api.github.com/meta",
+		Name:             "GitHub",
+		Description:      "GitHub IP Ranges of its services, like webhooks and Actions runners",
+		PrefixCategories: overrideCategories(githubServiceCategories),
+		DataFilename:     "github.bin",
+		RefreshInterval:  24 * time.Hour,
+		Source:           ParserFunc(parseGitHub),
+	},
+	"cloudflare": {
+		URL:             "https://www.cloudflare.com/ips-v4",
+		Name:            "Cloudflare",
+		Description:     "Cloudflare CDN IP Ranges",
+		Categories:      []Category{Categories["cdn"]},
+		DataFilename:    "cloudflare.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          textLists{Extra: []string{"https://www.cloudflare.com/ips-v6"}},
+	},
+	"fastly": {
+		URL:             "https://api.fastly.com/public-ip-list",
+		Name:            "Fastly",
+		Description:     "Fastly CDN IP Ranges",
+		Categories:      []Category{Categories["cdn"]},
+		DataFilename:    "fastly.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parseFastly),
+	},
+	"bunnycdn": {
+		URL:             "https://bunnycdn.com/api/system/edgeserverlist/plain",
+		Name:            "Bunny CDN",
+		Description:     "Bunny CDN IPv4 and IPv6 Addresses of the edge servers",
+		Categories:      []Category{Categories["cdn"]},
+		DataFilename:    "bunnycdn.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source: textLists{
+			Extra:  []string{"https://bunnycdn.com/api/system/edgeserverlist/ipv6"},
+			Parser: parseBunny,
+		},
 	},
 	"google-bot": {
 		URL:             "https://developers.google.com/static/search/apis/ipranges/googlebot.json",
 		Name:            "GoogleBot",
 		Description:     "GoogleBot IP Ranges of the main crawlers",
 		Categories:      []Category{Categories["crawler"]},
-		DataFilename:    filepath.Join(utils.GetDataDirectory(), "googlebot.bin"),
+		DataFilename:    "googlebot.bin",
 		RefreshInterval: 24 * time.Hour,
-		Fetcher:         fetchGoogleBotData,
+		Source:          ParserFunc(parsePrefixList),
 	},
 	"google-bot-special": {
 		URL:             "https://developers.google.com/static/search/apis/ipranges/special-crawlers.json",
 		Name:            "GoogleBot Special Crawlers",
 		Description:     "GoogleBot IP Ranges of the special crawlers",
 		Categories:      []Category{Categories["crawler"]},
-		DataFilename:    filepath.Join(utils.GetDataDirectory(), "googlebot-special.bin"),
+		DataFilename:    "googlebot-special.bin",
 		RefreshInterval: 24 * time.Hour,
-		Fetcher:         fetchGoogleBotData,
+		Source:          ParserFunc(parsePrefixList),
 	},
 	"google-user-triggered-fetchers-google": {
 		URL:             "https://developers.google.com/static/search/apis/ipranges/user-triggered-fetchers-google.json",
 		Name:            "GoogleBot Users Triggered (Google)",
 		Description:     "GoogleBot IP Ranges of the user triggered crawlers (google IPs)",
 		Categories:      []Category{Categories["crawler"]},
-		DataFilename:    filepath.Join(utils.GetDataDirectory(), "google-user-triggered-fetchers-google.bin"),
+		DataFilename:    "google-user-triggered-fetchers-google.bin",
 		RefreshInterval: 24 * time.Hour,
-		Fetcher:         fetchGoogleBotData,
+		Source:          ParserFunc(parsePrefixList),
 	},
 	"bingbot": {
 		URL:             "https://www.bing.com/toolbox/bingbot.json",
 		Name:            "BingBot",
 		Description:     "BingBot IP Ranges",
 		Categories:      []Category{Categories["crawler"]},
-		DataFilename:    filepath.Join(utils.GetDataDirectory(), "bingbot.bin"),
+		DataFilename:    "bingbot.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parsePrefixList),
+	},
+	"applebot": {
+		URL:             "https://search.developer.apple.com/applebot.json",
+		Name:            "Applebot",
+		Description:     "Applebot IP Ranges",
+		Categories:      []Category{Categories["crawler"]},
+		DataFilename:    "applebot.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parsePrefixList),
+	},
+	"duckduckbot": {
+		URL:             "https://duckduckgo.com/duckduckbot.json",
+		Name:            "DuckDuckBot",
+		Description:     "DuckDuckBot IP Ranges",
+		Categories:      []Category{Categories["crawler"]},
+		DataFilename:    "duckduckbot.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parsePrefixList),
+	},
+	"ahrefsbot": {
+		URL:             "https://api.ahrefs.com/v3/public/crawler-ip-ranges",
+		Name:            "AhrefsBot",
+		Description:     "AhrefsBot and AhrefsSiteAudit IP Ranges",
+		Categories:      []Category{Categories["crawler"]},
+		DataFilename:    "ahrefsbot.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parsePrefixList),
+	},
+	"openai-gptbot": {
+		URL:             "https://openai.com/gptbot.json",
+		Name:            "OpenAI GPTBot",
+		Description:     "OpenAI GPTBot IP Ranges of the crawler used to train its models",
+		Categories:      []Category{Categories["crawler"], Categories["ai"]},
+		DataFilename:    "openai-gptbot.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parsePrefixList),
+	},
+	"openai-chatgpt-user": {
+		URL:             "https://openai.com/chatgpt-user.json",
+		Name:            "OpenAI ChatGPT-User",
+		Description:     "OpenAI ChatGPT-User IP Ranges of the fetches triggered by ChatGPT users",
+		Categories:      []Category{Categories["crawler"], Categories["ai"]},
+		DataFilename:    "openai-chatgpt-user.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parsePrefixList),
+	},
+	"openai-searchbot": {
+		URL:             "https://openai.com/searchbot.json",
+		Name:            "OpenAI OAI-SearchBot",
+		Description:     "OpenAI OAI-SearchBot IP Ranges of the crawler of the ChatGPT search",
+		Categories:      []Category{Categories["crawler"], Categories["ai"]},
+		DataFilename:    "openai-searchbot.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parsePrefixList),
+	},
+	"perplexitybot": {
+		URL:             "https://www.perplexity.com/perplexitybot.json",
+		Name:            "PerplexityBot",
+		Description:     "PerplexityBot IP Ranges of the crawler of the Perplexity search",
+		Categories:      []Category{Categories["crawler"], Categories["ai"]},
+		DataFilename:    "perplexitybot.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parsePrefixList),
+	},
+	"perplexity-user": {
+		URL:             "https://www.perplexity.com/perplexity-user.json",
+		Name:            "Perplexity-User",
+		Description:     "Perplexity-User IP Ranges of the fetches triggered by Perplexity users",
+		Categories:      []Category{Categories["crawler"], Categories["ai"]},
+		DataFilename:    "perplexity-user.bin",
+		RefreshInterval: 24 * time.Hour,
+		Source:          ParserFunc(parsePrefixList),
+	},
+	"ccbot": {
+		URL:             "https://index.commoncrawl.org/ccbot.json",
+		Name:            "Common Crawl CCBot",
+		Description:     "Common Crawl CCBot IP Ranges, its crawls are widely used to train AI models",
+		Categories:      []Category{Categories["crawler"], Categories["ai"]},
+		DataFilename:    "ccbot.bin",
 		RefreshInterval: 24 * time.Hour,
-		Fetcher:         fetchBingBotData,
+		Source:          ParserFunc(parsePrefixList),
 	},
 }
@@ -1,7 +1,17 @@
 package sources
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -14,19 +24,18 @@ var testSources = map[string]*IPSource{
 		Description:     "A fake source for testing purposes",
 		Categories:      []Category{Categories["datacenter"]},
 		RefreshInterval: 1 * time.Minute,
-		Fetcher:         fetchFakeData,
+		Source:          fakeSource{},
 	},
 }
 
-// fetchFakeData fetches fake data for testing purposes.
-func fetchFakeData(src *IPSource) error {
-	src.Mu.Lock()
-	defer src.Mu.Unlock()
+// fakeSource is a Source that returns fake data for testing purposes.
+type fakeSource struct{}
 
-	if time.Since(src.MetaData.LastUpdate) < src.RefreshInterval {
-		return nil
-	}
+func (fakeSource) Fetch(ctx context.Context, url string, cached Validators) (io.ReadCloser, Validators, error) {
+	return io.NopCloser(strings.NewReader("")), Validators{}, nil
+}
 
+func (fakeSource) Parse(r io.Reader) (*Data, error) {
 	fakePrefixes := []Prefix{
 		{
 			Network: net.IPNet{
@@ -49,10 +58,7 @@ func fetchFakeData(src *IPSource) error {
 			},
 		},
 	}
-
-	src.MetaData.Prefixes = fakePrefixes
-	src.MetaData.LastUpdate = time.Now()
-	return nil
+	return &Data{Prefixes: fakePrefixes}, nil
 }
 
 func TestNoDuplicateDataFilename(t *testing.T) {
@@ -95,7 +101,7 @@ func TestFetchSourceDataConcurrency(t *testing.T) {
 			for i := 0; i < concurrentFetches; i++ {
 				go func() {
 					defer wg.Done()
-					err := source.Fetcher(source)
+					err := source.Refresh(context.Background())
 					if err != nil {
 						t.Errorf("Failed to fetch data: %v", err)
 					}
@@ -116,7 +122,7 @@ func TestFetchSourceDataConcurrency(t *testing.T) {
 func TestFetchFakeSourceData(t *testing.T) {
 	source := testSources["fake"]
 
-	err := source.Fetcher(source)
+	err := source.Refresh(context.Background())
 	if err != nil {
 		t.Errorf("Failed to fetch data for fake source: %v", err)
 	}
@@ -139,3 +145,471 @@ func TestFetchFakeSourceData(t *testing.T) {
 		t.Errorf("IP not found in prefixes: '%s'", ip)
 	}
 }
+
+func TestRefreshPublishesSnapshot(t *testing.T) {
+	source := &IPSource{
+		Name:            "Fake Snapshot Source",
+		RefreshInterval: 1 * time.Minute,
+		Source:          fakeSource{},
+	}
+
+	if source.Snapshot() != nil {
+		t.Fatalf("Expected no snapshot before the first refresh")
+	}
+
+	if err := source.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh fake source: %v", err)
+	}
+
+	ip := net.ParseIP("10.1.2.3")
+	prefix := source.Snapshot().ContainsIP(ip)
+	if prefix == nil {
+		t.Fatalf("IP not found in snapshot: '%s'", ip)
+	}
+	if prefix.Details["Service"] != "FakeService2" {
+		t.Errorf("Wrong prefix values. Expected: FakeService2, Got: %s", prefix.Details["Service"])
+	}
+}
+
+func TestParsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		parser   ParserFunc
+		data     string
+		expected []string
+		details  map[string]string
+	}{
+		{
+			name:   "aws",
+			parser: parseAWS,
+			data: `{"syncToken": "1", "createDate": "2024-06-20-23-03-11", "prefixes": [
+				{"ip_prefix": "3.2.34.0/26", "region": "af-south-1", "service": "AMAZON", "network_border_group": "af-south-1"},
+				{"ip_prefix": "invalid", "region": "af-south-1", "service": "AMAZON", "network_border_group": "af-south-1"}]}`,
+			expected: []string{"3.2.34.0/26"},
+			details:  map[string]string{"Region": "af-south-1", "Service": "AMAZON", "NetworkBorderGroup": "af-south-1"},
+		},
+		{
+			name:   "google",
+			parser: parseGoogle,
+			data: `{"syncToken": "1", "creationTime": "2024-06-20T23:03:11", "prefixes": [
+				{"ipv4Prefix": "34.1.208.0/20", "service": "Google Cloud", "scope": "africa-south1"},
+				{"ipv6Prefix": "2600:1900:8000::/44", "service": "Google Cloud", "scope": "africa-south1"}]}`,
+			expected: []string{"34.1.208.0/20", "2600:1900:8000::/44"},
+			details:  map[string]string{"Service": "Google Cloud", "Scope": "africa-south1"},
+		},
+		{
+			name:   "prefix list",
+			parser: parsePrefixList,
+			data: `{"creationTime": "2024-06-20T23:03:11", "prefixes": [
+				{"ipv6Prefix": "2001:4860:4801:10::/64"}, {"ipv4Prefix": "66.249.64.0/27"}]}`,
+			expected: []string{"2001:4860:4801:10::/64", "66.249.64.0/27"},
+		},
+		{
+			name:   "openai gptbot",
+			parser: IPRangeSources["openai-gptbot"].Source.(ParserFunc),
+			data: `{"creationTime": "2025-05-26T18:00:07.000000", "prefixes": [
+				{"ipv4Prefix": "52.230.152.0/24"}, {"ipv4Prefix": "20.171.206.0/24"}, {"ipv4Prefix": "invalid"}]}`,
+			expected: []string{"52.230.152.0/24", "20.171.206.0/24"},
+		},
+		{
+			name:   "oracle",
+			parser: parseOracle,
+			data: `{"last_updated_timestamp": "2024-06-18T22:10:57.108213", "regions": [{"region": "eu-madrid-1",
+				"cidrs": [{"cidr": "130.61.0.0/16", "tags": ["OCI", "OSN"]}, {"cidr": "invalid", "tags": ["OCI"]}]}]}`,
+			expected: []string{"130.61.0.0/16"},
+			details:  map[string]string{"Region": "eu-madrid-1", "Tags": "OCI,OSN"},
+		},
+		{
+			name:     "geofeed",
+			parser:   geofeedParser.Parse,
+			data:     "# Linode geofeed\n2600:3c00::/32,US,US-TX,Richardson,\n",
+			expected: []string{"2600:3c00::/32"},
+			details:  map[string]string{"Country": "US", "Region": "US-TX", "City": "Richardson"},
+		},
+		{
+			name:     "bunny",
+			parser:   parseBunny,
+			data:     "89.187.188.227\r\n89.187.188.228\r\n\n[\"2400:52e0:1500::1\",\"invalid\"]\n",
+			expected: []string{"89.187.188.227/32", "89.187.188.228/32", "2400:52e0:1500::1/128"},
+		},
+		{
+			name:     "fastly",
+			parser:   parseFastly,
+			data:     `{"addresses": ["23.235.32.0/20", "invalid"], "ipv6_addresses": ["2a04:4e40::/32"]}`,
+			expected: []string{"23.235.32.0/20", "2a04:4e40::/32"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.parser.Parse(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Failed to parse data: %v", err)
+			}
+
+			var got []string
+			for _, p := range data.Prefixes {
+				got = append(got, p.Network.String())
+				if !reflect.DeepEqual(p.Details, tt.details) {
+					t.Errorf("Wrong details for '%s'. Expected: %v, Got: %v", p.Network.String(), tt.details, p.Details)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Wrong prefixes. Expected: %v, Got: %v", tt.expected, got)
+			}
+		})
+	}
+
+	cloudfront := `{"prefixes": [{"ip_prefix": "3.160.0.0/14", "region": "GLOBAL", "service": "AMAZON"},
+		{"ip_prefix": "3.160.0.0/14", "region": "GLOBAL", "service": "CLOUDFRONT"},
+		{"ip_prefix": "3.2.34.0/26", "region": "af-south-1", "service": "AMAZON"}]}`
+	data, err := parseAWS(strings.NewReader(cloudfront))
+	if err != nil {
+		t.Fatalf("Failed to parse data: %v", err)
+	}
+	if expected := []string{"3.160.0.0/14", "3.2.34.0/26"}; !reflect.DeepEqual(networksOf(data.Prefixes), expected) {
+		t.Fatalf("Wrong prefixes. Expected: %v, Got: %v", expected, networksOf(data.Prefixes))
+	}
+	if !reflect.DeepEqual(data.Prefixes[0].Categories, []Category{Categories["cdn"]}) {
+		t.Errorf("Wrong categories for CloudFront: %v", data.Prefixes[0].Categories)
+	}
+	if service := data.Prefixes[0].Details["Service"]; service != "CLOUDFRONT" {
+		t.Errorf("Wrong service for CloudFront. Expected: CLOUDFRONT, Got: %s", service)
+	}
+
+	if _, err := ParserFunc(parseAWS).Parse(strings.NewReader("not json")); err == nil {
+		t.Errorf("Expected an error parsing invalid data")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	source := &IPSource{Name: "Fake Registered Source", Source: fakeSource{}}
+
+	if err := Register("fake-registered", source); err != nil {
+		t.Fatalf("Failed to register source: %v", err)
+	}
+	defer Unregister("fake-registered")
+
+	if Registered()["fake-registered"] != source {
+		t.Errorf("Registered source not found")
+	}
+	if err := Register("fake-registered", source); err == nil {
+		t.Errorf("Expected an error registering a duplicate key")
+	}
+	if err := Register("fake-empty", &IPSource{Name: "Fake Empty Source"}); err == nil {
+		t.Errorf("Expected an error registering a source without a Source")
+	}
+
+	Unregister("fake-registered")
+	if _, ok := Registered()["fake-registered"]; ok {
+		t.Errorf("Unregistered source still registered")
+	}
+}
+
+func TestDataDirectory(t *testing.T) {
+	defer func() {
+		dataDirMu.Lock()
+		dataDir, dataDirSet, dataDirCreated = "", false, false
+		dataDirMu.Unlock()
+	}()
+
+	source := &IPSource{Name: "Fake Saved Source", DataFilename: "fake.bin", RefreshInterval: 1 * time.Minute, Source: fakeSource{}}
+
+	dir := filepath.Join(t.TempDir(), "data")
+	SetDataDirectory(dir)
+	if err := source.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh fake source: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fake.bin")); err != nil {
+		t.Errorf("Data file not saved in the data directory: %v", err)
+	}
+
+	SetDataDirectory("")
+	source = &IPSource{Name: "Fake Memory Source", DataFilename: "memory.bin", RefreshInterval: 1 * time.Minute, Source: fakeSource{}}
+	if err := source.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh fake source: %v", err)
+	}
+	if path, _ := source.dataPath(); path != "" {
+		t.Errorf("Expected no data file in the in-memory mode, got '%s'", path)
+	}
+	if len(source.Snapshot().Prefixes) == 0 {
+		t.Errorf("No IP ranges fetched for the in-memory source")
+	}
+}
+
+func TestSaveReplacesDataFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fake.bin")
+	if err := os.WriteFile(path, []byte("old data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := &IPSource{Name: "Fake Saved Source", RefreshInterval: 1 * time.Minute}
+	data, _ := fakeSource{}.Parse(nil)
+	source.MetaData.Prefixes = data.Prefixes
+	source.MetaData.LastUpdate = time.Now()
+	if err := source.save(path); err != nil {
+		t.Fatalf("Failed to save data: %v", err)
+	}
+
+	loaded := &IPSource{Name: "Fake Loaded Source", RefreshInterval: 1 * time.Minute}
+	if !loaded.load(path) || len(loaded.MetaData.Prefixes) != 2 {
+		t.Errorf("Failed to load the saved data: %+v", loaded.MetaData)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected only the data file, got %d files", len(files))
+	}
+
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0o644 {
+		t.Errorf("Wrong data file mode. Expected: %v, Got: %v", os.FileMode(0o644), info.Mode().Perm())
+	}
+
+	if err := source.save(filepath.Join(dir, "missing", "fake.bin")); err == nil {
+		t.Errorf("Expected an error saving to a missing directory")
+	}
+}
+
+// failingSource is a Source that fails to fetch its data.
+type failingSource struct{}
+
+func (failingSource) Fetch(ctx context.Context, url string, cached Validators) (io.ReadCloser, Validators, error) {
+	return nil, Validators{}, errors.New("fake network error")
+}
+
+func (failingSource) Parse(r io.Reader) (*Data, error) {
+	return nil, nil
+}
+
+func TestRefreshKeepsStaleData(t *testing.T) {
+	source := &IPSource{
+		Name:            "Fake Stale Source",
+		RefreshInterval: 1 * time.Hour,
+		MaxStaleAge:     24 * time.Hour,
+		Source:          failingSource{},
+	}
+	data, _ := fakeSource{}.Parse(nil)
+	source.MetaData.Prefixes = data.Prefixes
+	source.MetaData.LastUpdate = time.Now().Add(-2 * time.Hour)
+
+	if err := source.Refresh(context.Background()); err == nil {
+		t.Fatalf("Expected an error refreshing a failing source")
+	}
+
+	status := source.Status()
+	if !status.Stale || status.Error == nil || status.LastUpdate != source.MetaData.LastUpdate {
+		t.Errorf("Wrong status of the stale source: %+v", status)
+	}
+	if source.Snapshot().ContainsIP(net.ParseIP("10.1.2.3")) == nil {
+		t.Errorf("Stale data not published")
+	}
+
+	source.MetaData.LastUpdate = time.Now().Add(-48 * time.Hour)
+	source.Refresh(context.Background())
+	if status := source.Status(); len(source.Snapshot().Prefixes) != 0 || !status.LastUpdate.IsZero() || status.Stale {
+		t.Errorf("Data older than the max stale age still published: %+v", status)
+	}
+
+	source.Source = fakeSource{}
+	if err := source.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh fake source: %v", err)
+	}
+	if status := source.Status(); status.Stale || status.Error != nil {
+		t.Errorf("Wrong status after a successful refresh: %+v", status)
+	}
+}
+
+func TestConditionalRefresh(t *testing.T) {
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"syncToken": "1718924591", "createDate": "2024-06-20-23-03-11",
+			"prefixes": [{"ip_prefix": "3.2.34.0/26", "region": "af-south-1", "service": "AMAZON"}]}`))
+	}))
+	defer server.Close()
+
+	source := &IPSource{
+		URL:             server.URL,
+		Name:            "Fake Conditional Source",
+		RefreshInterval: 1 * time.Hour,
+		Source:          ParserFunc(parseAWS),
+	}
+
+	if err := source.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh source: %v", err)
+	}
+	status := source.Status()
+	if status.SyncToken != "1718924591" || !status.Published.Equal(time.Date(2024, 6, 20, 23, 3, 11, 0, time.UTC)) {
+		t.Errorf("Wrong upstream metadata: %+v", status)
+	}
+	if source.MetaData.ETag != `"v1"` {
+		t.Errorf("Wrong ETag. Expected: \"v1\", Got: %s", source.MetaData.ETag)
+	}
+
+	expired := time.Now().Add(-2 * time.Hour)
+	source.MetaData.LastUpdate = expired
+	if err := source.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh source: %v", err)
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("Expected a conditional request, got %d requests and %d not modified responses", requests, notModified)
+	}
+	if !source.MetaData.LastUpdate.After(expired) || len(source.Snapshot().Prefixes) != 1 {
+		t.Errorf("Not modified data not kept as fresh: %+v", source.MetaData)
+	}
+}
+
+func TestAzure(t *testing.T) {
+	serviceTags := `{"changeNumber": 302, "cloud": "Public", "values": [
+		{"name": "AzureCloud", "properties": {"region": "", "systemService": "",
+			"addressPrefixes": ["13.107.246.0/24", "13.69.0.0/17"]}},
+		{"name": "AzureFrontDoor.Frontend", "properties": {"region": "", "systemService": "AzureFrontDoor",
+			"addressPrefixes": ["13.107.246.0/24", "2620:1ec:bdf::/48"]}},
+		{"name": "AzureCloud.westeurope", "properties": {"region": "westeurope", "systemService": "",
+			"addressPrefixes": ["13.69.0.0/17"]}}]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/details.aspx":
+			w.Write([]byte(`<a href="https://download.microsoft.com/download/7/1/D/71D86715/ServiceTags_Public_20240617.json">`))
+		default:
+			w.Write([]byte(serviceTags))
+		}
+	}))
+	defer server.Close()
+
+	// Only Front Door overrides the categories, so crawler filters never download the tags.
+	if expected := []Category{Categories["cdn"]}; !reflect.DeepEqual(overrideCategories(azureServiceCategories), expected) {
+		t.Errorf("Wrong override categories. Expected: %v, Got: %v", expected, overrideCategories(azureServiceCategories))
+	}
+
+	if _, err := azureFileURL(context.Background(), server.URL+"/details.aspx"); err != nil {
+		t.Errorf("Failed to find the service tags file: %v", err)
+	}
+	if _, err := azureFileURL(context.Background(), server.URL+"/ServiceTags_Public_20240617.json"); err == nil {
+		t.Errorf("Expected an error for a page without the service tags file")
+	}
+
+	path := filepath.Join(t.TempDir(), "ServiceTags_Public_20240617.json")
+	if err := os.WriteFile(path, []byte(serviceTags), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{server.URL + "/ServiceTags_Public_20240617.json", path} {
+		source := &IPSource{
+			URL:             url,
+			Name:            "Fake Azure Source",
+			Categories:      []Category{Categories["datacenter"]},
+			RefreshInterval: 1 * time.Hour,
+			Source:          azureSource{parseAzure},
+		}
+		if err := source.Refresh(context.Background()); err != nil {
+			t.Fatalf("Failed to refresh source: %v", err)
+		}
+
+		data := source.Snapshot()
+		expected := []string{"13.107.246.0/24", "13.69.0.0/17", "2620:1ec:bdf::/48"}
+		if got := networksOf(data.Prefixes); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Wrong prefixes. Expected: %v, Got: %v", expected, got)
+		}
+		if data.SyncToken != "302" {
+			t.Errorf("Wrong sync token. Expected: 302, Got: %s", data.SyncToken)
+		}
+
+		frontDoor, region := data.Prefixes[0], data.Prefixes[1]
+		if len(frontDoor.Categories) != 1 || frontDoor.Categories[0].ID != "cdn" {
+			t.Errorf("Wrong categories for AzureFrontDoor: %v", frontDoor.Categories)
+		}
+		if expected := map[string]string{"Tag": "AzureCloud,AzureFrontDoor.Frontend", "Service": "AzureFrontDoor"}; !reflect.DeepEqual(frontDoor.Details, expected) {
+			t.Errorf("Wrong details. Expected: %v, Got: %v", expected, frontDoor.Details)
+		}
+		if expected := map[string]string{"Tag": "AzureCloud,AzureCloud.westeurope", "Region": "westeurope"}; !reflect.DeepEqual(region.Details, expected) {
+			t.Errorf("Wrong details. Expected: %v, Got: %v", expected, region.Details)
+		}
+		if len(region.Categories) != 0 {
+			t.Errorf("Unexpected categories for AzureCloud: %v", region.Categories)
+		}
+	}
+}
+
+func TestTextLists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ips-v4":
+			w.Write([]byte("173.245.48.0/20\n103.21.244.0/22"))
+		case "/ips-v6":
+			w.Write([]byte("2400:cb00::/32\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := &IPSource{
+		URL:             server.URL + "/ips-v4",
+		Name:            "Fake Text Lists Source",
+		RefreshInterval: 1 * time.Hour,
+		Source:          textLists{Extra: []string{server.URL + "/ips-v6"}},
+	}
+	if err := source.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh source: %v", err)
+	}
+
+	expected := []string{"173.245.48.0/20", "103.21.244.0/22", "2400:cb00::/32"}
+	if got := networksOf(source.Snapshot().Prefixes); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong prefixes. Expected: %v, Got: %v", expected, got)
+	}
+
+	source.Source = textLists{Extra: []string{server.URL + "/missing"}}
+	source.MetaData.LastUpdate = time.Time{}
+	if err := source.Refresh(context.Background()); err == nil {
+		t.Errorf("Expected an error when one of the lists fails")
+	}
+}
+
+func TestParseGitHub(t *testing.T) {
+	meta := `{"verifiable_password_authentication": false, "ssh_keys": ["ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"],
+		"hooks": ["192.30.252.0/22", "2a0a:a440::/29"], "actions": ["4.148.0.0/16"], "web": ["140.82.112.0/20"],
+		"api": ["192.30.252.0/22"],
+		"domains": {"website": ["*.github.com"]}}`
+
+	data, err := parseGitHub(strings.NewReader(meta))
+	if err != nil {
+		t.Fatalf("Failed to parse data: %v", err)
+	}
+
+	expected := []string{"4.148.0.0/16", "192.30.252.0/22", "2a0a:a440::/29", "140.82.112.0/20"}
+	if got := networksOf(data.Prefixes); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Wrong prefixes. Expected: %v, Got: %v", expected, got)
+	}
+
+	// Actions runners and the services of GitHub itself end up in different categories.
+	categories := []string{"datacenter", "", "", ""}
+	services := []string{"actions", "api,hooks", "hooks", "web"}
+	for i, p := range data.Prefixes {
+		if p.Details["Service"] != services[i] {
+			t.Errorf("Wrong service for '%s'. Expected: %s, Got: %s", p.Network.String(), services[i], p.Details["Service"])
+		}
+		var got string
+		if len(p.Categories) > 0 {
+			got = p.Categories[0].ID
+		}
+		if got != categories[i] {
+			t.Errorf("Wrong category for '%s'. Expected: %s, Got: %s", p.Network.String(), categories[i], got)
+		}
+	}
+
+	if expected := []Category{Categories["datacenter"]}; !reflect.DeepEqual(overrideCategories(githubServiceCategories), expected) {
+		t.Errorf("Wrong override categories. Expected: %v, Got: %v", expected, overrideCategories(githubServiceCategories))
+	}
+	if src := IPRangeSources["github"]; len(src.Categories) != 0 {
+		t.Errorf("Unexpected default categories, they would apply to every service: %v", src.Categories)
+	}
+}
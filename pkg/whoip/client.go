@@ -0,0 +1,134 @@
+package whoip
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aorith/whoip/pkg/sources"
+)
+
+// Client looks up addresses in a set of sources.
+// The zero value uses the sources registered in sources.IPRangeSources,
+// like the package-level functions.
+type Client struct {
+	srcs map[string]*sources.IPSource // nil to use the registered sources.
+
+	currentIndex atomic.Pointer[index]
+	indexMu      sync.Mutex
+}
+
+// defaultClient is the client used by the package-level functions.
+var defaultClient = &Client{}
+
+// NewClient returns a client that only uses the given sources, keyed like
+// sources.IPRangeSources. The map is copied, the sources are not.
+func NewClient(srcs map[string]*sources.IPSource) *Client {
+	c := &Client{srcs: make(map[string]*sources.IPSource, len(srcs))}
+	for key, src := range srcs {
+		c.srcs[key] = src
+	}
+	return c
+}
+
+// Sources returns the sources of the client.
+func (c *Client) Sources() map[string]*sources.IPSource {
+	if c.srcs == nil {
+		return sources.Registered()
+	}
+	return c.srcs
+}
+
+// ValidateFilter checks that every source and category of the filter exists.
+func (c *Client) ValidateFilter(f Filter) error {
+	srcs := c.Sources()
+	for _, key := range slices.Concat(f.Sources, f.ExcludeSources) {
+		if _, ok := srcs[key]; !ok {
+			return fmt.Errorf("unknown source: %s", key)
+		}
+	}
+	for _, id := range slices.Concat(f.Categories, f.ExcludeCategories) {
+		if _, ok := sources.Categories[id]; !ok {
+			return fmt.Errorf("unknown category: %s", id)
+		}
+	}
+	return nil
+}
+
+// Pending reports whether any source selected by the filter has not published
+// data yet, because its first refresh is still in progress.
+func (c *Client) Pending(filter Filter) bool {
+	for _, src := range filter.SelectSources(c.Sources()) {
+		if src.Snapshot() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// index returns the index of the sources, rebuilding it if any of the sources
+// published new data since it was built.
+func (c *Client) index() *index {
+	srcs := c.Sources()
+	if idx := c.currentIndex.Load(); idx != nil && idx.isCurrent(srcs) {
+		return idx
+	}
+
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+
+	if idx := c.currentIndex.Load(); idx != nil && idx.isCurrent(srcs) {
+		return idx
+	}
+	idx := buildIndex(srcs)
+	c.currentIndex.Store(idx)
+	return idx
+}
+
+// Pending calls Client.Pending on the registered sources.
+func Pending(filter Filter) bool {
+	return defaultClient.Pending(filter)
+}
+
+// Lookup calls Client.Lookup on the registered sources.
+func Lookup(ctx context.Context, addr netip.Addr, filter Filter) ([]WhoIPInfo, error) {
+	return defaultClient.Lookup(ctx, addr, filter)
+}
+
+// Match calls Client.Match on the registered sources.
+func Match(addr netip.Addr, filter Filter) []WhoIPInfo {
+	return defaultClient.Match(addr, filter)
+}
+
+// MatchString calls Client.MatchString on the registered sources.
+func MatchString(query string, filter Filter) Result {
+	return defaultClient.MatchString(query, filter)
+}
+
+// LookupRange calls Client.LookupRange on the registered sources.
+func LookupRange(ctx context.Context, r Range, filter Filter) ([]WhoIPInfo, error) {
+	return defaultClient.LookupRange(ctx, r, filter)
+}
+
+// MatchRange calls Client.MatchRange on the registered sources.
+func MatchRange(r Range, filter Filter) []WhoIPInfo {
+	return defaultClient.MatchRange(r, filter)
+}
+
+// UpdateSources calls Client.UpdateSources on the registered sources.
+func UpdateSources(ctx context.Context, filter Filter) error {
+	return defaultClient.UpdateSources(ctx, filter)
+}
+
+// Entries calls Client.Entries on the registered sources.
+func Entries(filter Filter) []Entry {
+	return defaultClient.Entries(filter)
+}
+
+// RunRefresher calls Client.RunRefresher on the registered sources.
+func RunRefresher(ctx context.Context) {
+	defaultClient.RunRefresher(ctx)
+}
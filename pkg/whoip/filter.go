@@ -0,0 +1,100 @@
+package whoip
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aorith/whoip/pkg/sources"
+)
+
+// Filter restricts lookups to a subset of the sources and categories.
+// Empty fields match everything.
+type Filter struct {
+	Sources           []string // Keys of the sources to use.
+	Categories        []string // IDs of the categories to use.
+	ExcludeSources    []string // Keys of the sources to skip.
+	ExcludeCategories []string // IDs of the categories to skip.
+}
+
+// ParseList splits a comma separated list, ignoring empty items and surrounding spaces.
+func ParseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate checks that every category of the filter exists and every source is registered.
+// Use Client.ValidateFilter for filters of clients with their own sources.
+func (f Filter) Validate() error {
+	return defaultClient.ValidateFilter(f)
+}
+
+// IsEmpty reports whether the filter matches everything.
+func (f Filter) IsEmpty() bool {
+	return len(f.Sources) == 0 && len(f.Categories) == 0 && len(f.ExcludeSources) == 0 && len(f.ExcludeCategories) == 0
+}
+
+// MatchSource reports whether the source with the given key is selected by the filter,
+// based on its key, its default categories and the categories of its prefixes.
+func (f Filter) MatchSource(key string, src *sources.IPSource) bool {
+	if len(f.Sources) > 0 && !slices.Contains(f.Sources, key) {
+		return false
+	}
+	if slices.Contains(f.ExcludeSources, key) {
+		return false
+	}
+	if f.matchCategories(src.Categories) {
+		return true
+	}
+	for _, cat := range src.PrefixCategories {
+		if f.matchCategories([]sources.Category{cat}) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectSources returns the sources selected by the filter.
+func (f Filter) SelectSources(srcs map[string]*sources.IPSource) map[string]*sources.IPSource {
+	selected := make(map[string]*sources.IPSource)
+	for key, src := range srcs {
+		if f.MatchSource(key, src) {
+			selected[key] = src
+		}
+	}
+	return selected
+}
+
+// matchEntry reports whether a matched prefix is selected by the filter,
+// taking into account the category overrides of the prefix.
+func (f Filter) matchEntry(entry indexEntry) bool {
+	if len(f.Sources) > 0 && !slices.Contains(f.Sources, entry.key) {
+		return false
+	}
+	if slices.Contains(f.ExcludeSources, entry.key) {
+		return false
+	}
+	if len(entry.prefix.Categories) > 0 {
+		return f.matchCategories(entry.prefix.Categories)
+	}
+	return f.matchCategories(entry.source.Categories)
+}
+
+// matchCategories reports whether any of the categories is selected and none is excluded.
+func (f Filter) matchCategories(categories []sources.Category) bool {
+	selected := len(f.Categories) == 0
+	for _, cat := range categories {
+		if slices.Contains(f.ExcludeCategories, cat.ID) {
+			return false
+		}
+		if slices.Contains(f.Categories, cat.ID) {
+			selected = true
+		}
+	}
+	return selected
+}
@@ -0,0 +1,100 @@
+package whoip
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/aorith/whoip/pkg/sources"
+)
+
+// indexEntry is a prefix of a source stored in the index.
+type indexEntry struct {
+	key    string
+	source *sources.IPSource
+	prefix *sources.Prefix
+}
+
+// index is a longest-prefix-match index of the prefixes of all the sources.
+type index struct {
+	v4        *trie[indexEntry]
+	v6        *trie[indexEntry]
+	snapshots map[string]*sources.IPMetaData
+}
+
+// buildIndex builds a new index from the current snapshot of every source.
+func buildIndex(srcs map[string]*sources.IPSource) *index {
+	idx := &index{
+		v4:        newTrie[indexEntry](netip.MustParsePrefix("0.0.0.0/0")),
+		v6:        newTrie[indexEntry](netip.MustParsePrefix("::/0")),
+		snapshots: make(map[string]*sources.IPMetaData, len(srcs)),
+	}
+
+	// Insert the sources in a stable order so matches of the same length are deterministic.
+	keys := make([]string, 0, len(srcs))
+	for key := range srcs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		src := srcs[key]
+		data := src.Snapshot()
+		idx.snapshots[key] = data
+		if data == nil {
+			continue
+		}
+		for i := range data.Prefixes {
+			prefix, ok := data.Prefixes[i].NetipPrefix()
+			if !ok {
+				continue
+			}
+			entry := indexEntry{key: key, source: src, prefix: &data.Prefixes[i]}
+			if prefix.Addr().Is4() {
+				idx.v4.insert(prefix.Masked(), entry)
+			} else {
+				idx.v6.insert(prefix.Masked(), entry)
+			}
+		}
+	}
+
+	return idx
+}
+
+// isCurrent reports whether the index was built from the current snapshot of every source.
+func (idx *index) isCurrent(srcs map[string]*sources.IPSource) bool {
+	if len(idx.snapshots) != len(srcs) {
+		return false
+	}
+	for key, src := range srcs {
+		data, ok := idx.snapshots[key]
+		if !ok || data != src.Snapshot() {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns every indexed prefix that contains the address, from the most
+// to the least specific.
+func (idx *index) lookup(addr netip.Addr) []indexEntry {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return idx.v4.lookup(addr)
+	}
+	return idx.v6.lookup(addr)
+}
+
+// overlapping returns every indexed prefix that overlaps the range.
+// The same prefix can be returned more than once.
+func (idx *index) overlapping(r Range) []indexEntry {
+	t := idx.v6
+	if r.From.Is4() {
+		t = idx.v4
+	}
+
+	var entries []indexEntry
+	for _, prefix := range r.Prefixes() {
+		entries = append(entries, t.overlapping(prefix)...)
+	}
+	return entries
+}
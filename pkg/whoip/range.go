@@ -0,0 +1,201 @@
+package whoip
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/aorith/whoip/pkg/sources"
+)
+
+// Overlap describes how a known prefix relates to a queried network or range.
+type Overlap string
+
+const (
+	OverlapEqual      Overlap = "equal"      // The prefix is the queried network.
+	OverlapContained  Overlap = "contained"  // The prefix is inside the queried network.
+	OverlapContaining Overlap = "containing" // The prefix contains the queried network.
+	OverlapPartial    Overlap = "partial"    // The prefix and the queried range partially overlap.
+)
+
+// Range is an inclusive range of IP addresses of the same family.
+type Range struct {
+	From netip.Addr
+	To   netip.Addr
+}
+
+// ParseRange parses an IP address, a CIDR such as '3.5.0.0/16' or an address
+// range such as '10.0.0.1-10.0.0.50' into a Range.
+func ParseRange(s string) (Range, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid CIDR: %s", s)
+		}
+		return RangeFromPrefix(prefix), nil
+	}
+
+	fromStr, toStr, found := strings.Cut(s, "-")
+	if !found {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid IP address: %s", s)
+		}
+		addr = addr.Unmap().WithZone("")
+		return Range{From: addr, To: addr}, nil
+	}
+
+	from, err := netip.ParseAddr(strings.TrimSpace(fromStr))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid range start: %s", fromStr)
+	}
+	to, err := netip.ParseAddr(strings.TrimSpace(toStr))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid range end: %s", toStr)
+	}
+
+	r := Range{From: from.Unmap().WithZone(""), To: to.Unmap().WithZone("")}
+	if r.From.Is4() != r.To.Is4() {
+		return Range{}, fmt.Errorf("range mixes IPv4 and IPv6 addresses: %s", s)
+	}
+	if r.To.Less(r.From) {
+		return Range{}, fmt.Errorf("range end is before its start: %s", s)
+	}
+	return r, nil
+}
+
+// RangeFromPrefix returns the range of addresses of the prefix.
+func RangeFromPrefix(prefix netip.Prefix) Range {
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	prefix = prefix.Masked()
+	return Range{From: prefix.Addr(), To: lastAddr(prefix)}
+}
+
+// String returns the range as an address, a CIDR or an 'a-b' range.
+func (r Range) String() string {
+	if r.From == r.To {
+		return r.From.String()
+	}
+	if prefixes := r.Prefixes(); len(prefixes) == 1 {
+		return prefixes[0].String()
+	}
+	return fmt.Sprintf("%s-%s", r.From, r.To)
+}
+
+// Contains reports whether the address is inside the range.
+func (r Range) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.Is4() == r.From.Is4() && !addr.Less(r.From) && !r.To.Less(addr)
+}
+
+// Prefixes returns the smallest list of prefixes that covers exactly the range.
+func (r Range) Prefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	from := r.From
+	for from.IsValid() && !r.To.Less(from) {
+		// Find the biggest prefix that starts at 'from' and ends before 'to'.
+		var prefix netip.Prefix
+		for bits := 0; bits <= from.BitLen(); bits++ {
+			p := netip.PrefixFrom(from, bits)
+			if p.Masked().Addr() == from && !r.To.Less(lastAddr(p)) {
+				prefix = p
+				break
+			}
+		}
+		prefixes = append(prefixes, prefix)
+		from = lastAddr(prefix).Next()
+	}
+	return prefixes
+}
+
+// overlap returns how the prefix relates to the range.
+func (r Range) overlap(prefix netip.Prefix) Overlap {
+	pr := RangeFromPrefix(prefix)
+	switch {
+	case pr == r:
+		return OverlapEqual
+	case !pr.From.Less(r.From) && !r.To.Less(pr.To):
+		return OverlapContained
+	case !r.From.Less(pr.From) && !pr.To.Less(r.To):
+		return OverlapContaining
+	}
+	return OverlapPartial
+}
+
+// lastAddr returns the last address of the prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Masked().Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// LookupRange updates the sources selected by the filter and returns the information
+// of every selected source with prefixes that overlap the range.
+// Like Lookup, sources that fail to update are looked up with the data they already have.
+func (c *Client) LookupRange(ctx context.Context, r Range, filter Filter) ([]WhoIPInfo, error) {
+	if !r.From.IsValid() || !r.To.IsValid() {
+		return nil, fmt.Errorf("invalid range: %s", r)
+	}
+
+	if err := c.UpdateSources(ctx, filter); err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return c.MatchRange(r, filter), nil
+}
+
+// MatchRange returns the information of every source selected by the filter with
+// prefixes that overlap the range. Every prefix reports how it overlaps the range,
+// prefixes are ordered by address and from the least to the most specific.
+// Like Match, it only uses the current snapshot of the sources.
+func (c *Client) MatchRange(r Range, filter Filter) []WhoIPInfo {
+	return matchIndexRange(c.index(), r, filter)
+}
+
+// matchIndexRange groups the prefixes of the index that overlap the range by source.
+func matchIndexRange(idx *index, r Range, filter Filter) []WhoIPInfo {
+	type match struct {
+		entry  indexEntry
+		prefix netip.Prefix
+	}
+
+	var matches []match
+	seen := make(map[*sources.Prefix]bool)
+	for _, entry := range idx.overlapping(r) {
+		if seen[entry.prefix] {
+			continue
+		}
+		seen[entry.prefix] = true
+		if prefix, ok := entry.prefix.NetipPrefix(); ok {
+			matches = append(matches, match{entry, prefix.Masked()})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i].prefix, matches[j].prefix
+		if a.Addr() != b.Addr() {
+			return a.Addr().Less(b.Addr())
+		}
+		return a.Bits() < b.Bits()
+	})
+
+	entries := make([]indexEntry, len(matches))
+	overlaps := make(map[*sources.Prefix]Overlap, len(matches))
+	for i, m := range matches {
+		entries[i] = m.entry
+		overlaps[m.entry.prefix] = r.overlap(m.prefix)
+	}
+
+	return groupEntries(entries, filter, func(entry indexEntry) Overlap {
+		return overlaps[entry.prefix]
+	})
+}
@@ -0,0 +1,118 @@
+package whoip
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/aorith/whoip/pkg/sources"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		prefixes []string
+	}{
+		{"3.5.1.2/16", "3.5.0.0/16", []string{"3.5.0.0/16"}},
+		{"10.0.0.1", "10.0.0.1", []string{"10.0.0.1/32"}},
+		{"10.0.0.0-10.0.0.255", "10.0.0.0/24", []string{"10.0.0.0/24"}},
+		{"10.0.0.1 - 10.0.0.6", "10.0.0.1-10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"::ffff:10.0.0.0/104", "10.0.0.0/8", []string{"10.0.0.0/8"}},
+		{"2001:db8::-2001:db8::ffff", "2001:db8::/112", []string{"2001:db8::/112"}},
+		{"0.0.0.0-255.255.255.255", "0.0.0.0/0", []string{"0.0.0.0/0"}},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseRange(tt.input)
+		if err != nil {
+			t.Errorf("Failed to parse '%s': %v", tt.input, err)
+			continue
+		}
+		if r.String() != tt.expected {
+			t.Errorf("Wrong range for '%s'. Expected: %s, Got: %s", tt.input, tt.expected, r)
+		}
+
+		var prefixes []string
+		for _, p := range r.Prefixes() {
+			prefixes = append(prefixes, p.String())
+		}
+		if !reflect.DeepEqual(prefixes, tt.prefixes) {
+			t.Errorf("Wrong prefixes for '%s'. Expected: %v, Got: %v", tt.input, tt.prefixes, prefixes)
+		}
+	}
+
+	for _, input := range []string{"", "10.0.0.0/33", "10.0.0.9-10.0.0.1", "10.0.0.1-::1", "foo-bar"} {
+		if _, err := ParseRange(input); err == nil {
+			t.Errorf("Expected an error parsing '%s'", input)
+		}
+	}
+}
+
+func TestMatchRangeOverlap(t *testing.T) {
+	srcs := map[string]*sources.IPSource{
+		"cloud": newFakeSource(t, "Fake Cloud", map[string]string{
+			"3.0.0.0/8":    "AMAZON",
+			"3.5.0.0/16":   "EC2",
+			"3.5.140.0/22": "CLOUDFRONT",
+			"3.5.200.0/24": "S3",
+			"3.6.0.0/16":   "OTHER",
+		}),
+	}
+	idx := buildIndex(srcs)
+
+	tests := []struct {
+		query    string
+		expected map[string]Overlap
+	}{
+		{"3.5.0.0/16", map[string]Overlap{
+			"AMAZON":     OverlapContaining,
+			"EC2":        OverlapEqual,
+			"CLOUDFRONT": OverlapContained,
+			"S3":         OverlapContained,
+		}},
+		{"3.5.141.0-3.5.200.10", map[string]Overlap{
+			"AMAZON":     OverlapContaining,
+			"EC2":        OverlapContaining,
+			"CLOUDFRONT": OverlapPartial,
+			"S3":         OverlapPartial,
+		}},
+		{"4.0.0.0/8", map[string]Overlap{}},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseRange(tt.query)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %v", tt.query, err)
+		}
+
+		got := make(map[string]Overlap)
+		for _, info := range matchIndexRange(idx, r, Filter{}) {
+			for _, prefix := range info.Prefixes {
+				got[prefix.Details["Service"]] = prefix.Overlap
+			}
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Wrong overlaps for '%s'. Expected: %v, Got: %v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestTrieOverlapping(t *testing.T) {
+	tr := newTrie[string](netip.MustParsePrefix("0.0.0.0/0"))
+	for _, p := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "10.2.0.0/16", "11.0.0.0/8"} {
+		tr.insert(netip.MustParsePrefix(p), p)
+	}
+
+	got := tr.overlapping(netip.MustParsePrefix("10.1.0.0/16"))
+	expected := []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong overlapping prefixes. Expected output:\n%v\n\nGot:\n%v\n", expected, got)
+	}
+
+	got = tr.overlapping(netip.MustParsePrefix("10.0.0.0/7"))
+	expected = []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.3.0/24", "10.2.0.0/16", "11.0.0.0/8"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong overlapping prefixes. Expected output:\n%v\n\nGot:\n%v\n", expected, got)
+	}
+}
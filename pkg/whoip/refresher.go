@@ -0,0 +1,72 @@
+package whoip
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aorith/whoip/pkg/sources"
+)
+
+const (
+	// refreshJitter is the maximum fraction of the refresh interval added as random delay.
+	refreshJitter = 0.1
+	// minRetryDelay is the delay before retrying a failed refresh.
+	minRetryDelay = 1 * time.Minute
+)
+
+// RunRefresher refreshes every source in the background on its own RefreshInterval
+// until the context is canceled. Lookups done with Match keep using the last good
+// snapshot of each source while a refresh is in progress.
+// Sources registered after the refresher started are not refreshed by it.
+func (c *Client) RunRefresher(ctx context.Context) {
+	srcs := c.Sources()
+
+	var wg sync.WaitGroup
+	wg.Add(len(srcs))
+
+	for _, src := range srcs {
+		go func(src *sources.IPSource) {
+			defer wg.Done()
+			refreshLoop(ctx, src)
+		}(src)
+	}
+
+	wg.Wait()
+}
+
+// refreshLoop keeps a single source up to date until the context is canceled.
+func refreshLoop(ctx context.Context, src *sources.IPSource) {
+	retryDelay := minRetryDelay
+	for {
+		var delay time.Duration
+		if err := src.Refresh(ctx); err != nil {
+			log.Printf("Failure updating source '%s': %v", src.Name, err)
+			delay = retryDelay
+			retryDelay = min(retryDelay*2, src.RefreshInterval)
+		} else {
+			retryDelay = minRetryDelay
+			delay = nextRefresh(src)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// nextRefresh returns the time to wait until the data of the source expires, plus jitter.
+func nextRefresh(src *sources.IPSource) time.Duration {
+	var delay time.Duration
+	if data := src.Snapshot(); data != nil {
+		delay = src.RefreshInterval - time.Since(data.LastUpdate)
+	}
+	delay = max(delay, 0)
+	return delay + time.Duration(rand.Float64()*refreshJitter*float64(src.RefreshInterval))
+}
@@ -0,0 +1,272 @@
+package whoip
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aorith/whoip/pkg/sources"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format for lookup results.
+type Format string
+
+const (
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+	FormatTable  Format = "table"
+	FormatCSV    Format = "csv"
+	FormatYAML   Format = "yaml"
+)
+
+// Formats lists the supported output formats.
+var Formats = []Format{FormatJSON, FormatNDJSON, FormatTable, FormatCSV, FormatYAML}
+
+// WriteJSON writes v to w as indented JSON followed by a newline.
+func WriteJSON(w io.Writer, v any) error {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal json: %v", err)
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", jsonData)
+	return err
+}
+
+// WriteResults writes the results to w in the given format.
+func WriteResults(w io.Writer, format Format, results []Result) error {
+	switch format {
+	case FormatJSON:
+		return WriteJSON(w, results)
+	case FormatNDJSON:
+		return WriteNDJSON(w, results)
+	case FormatTable:
+		return WriteTable(w, results)
+	case FormatCSV:
+		return WriteCSV(w, results)
+	case FormatYAML:
+		return WriteYAML(w, results)
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+// WriteNDJSON writes every result as a JSON object on its own line.
+func WriteNDJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	for _, result := range results {
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode json: %v", err)
+		}
+	}
+	return nil
+}
+
+// resultRow is a flattened matching prefix of a result.
+type resultRow struct {
+	ip         string
+	name       string
+	network    string
+	categories string
+	details    map[string]string
+	overlap    Overlap
+	stale      bool
+	err        string
+}
+
+// flattenResults returns one row per matching prefix, results without matches
+// get a single row with only the IP address.
+func flattenResults(results []Result) []resultRow {
+	var rows []resultRow
+	for _, result := range results {
+		if len(result.Matches) == 0 {
+			rows = append(rows, resultRow{ip: result.IP, err: result.Error})
+			continue
+		}
+		for _, match := range result.Matches {
+			for _, prefix := range match.Prefixes {
+				categories := match.Categories
+				if len(prefix.Categories) > 0 {
+					categories = prefix.Categories
+				}
+				rows = append(rows, resultRow{
+					ip:         result.IP,
+					name:       match.Name,
+					network:    prefix.Network,
+					categories: joinCategories(categories),
+					details:    prefix.Details,
+					overlap:    prefix.Overlap,
+					stale:      match.Stale,
+				})
+			}
+		}
+	}
+	return rows
+}
+
+// hasOverlap reports whether any of the rows comes from a network or range query.
+func hasOverlap(rows []resultRow) bool {
+	for _, row := range rows {
+		if row.overlap != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasStale reports whether any of the rows comes from a source with stale data.
+func hasStale(rows []resultRow) bool {
+	for _, row := range rows {
+		if row.stale {
+			return true
+		}
+	}
+	return false
+}
+
+// joinCategories returns the IDs of the categories separated by commas.
+func joinCategories(categories []sources.Category) string {
+	ids := make([]string, len(categories))
+	for i, cat := range categories {
+		ids[i] = cat.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteTable writes the results as a human-readable aligned table with one row per matching prefix.
+// The OVERLAP column is only present when any of the results comes from a network or range query,
+// and the STALE column when any of the sources has stale data.
+func WriteTable(w io.Writer, results []Result) error {
+	rows := flattenResults(results)
+	overlap := hasOverlap(rows)
+	stale := hasStale(rows)
+
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"IP", "SOURCE", "NETWORK"}
+	if overlap {
+		header = append(header, "OVERLAP")
+	}
+	header = append(header, "CATEGORIES", "DETAILS")
+	if stale {
+		header = append(header, "STALE")
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, row := range rows {
+		name := orDash(row.name)
+		if row.err != "" {
+			name = "error: " + row.err
+		}
+
+		var details []string
+		for _, k := range sortedKeys(row.details) {
+			details = append(details, fmt.Sprintf("%s=%s", k, row.details[k]))
+		}
+
+		columns := []string{row.ip, name, orDash(row.network)}
+		if overlap {
+			columns = append(columns, orDash(string(row.overlap)))
+		}
+		columns = append(columns, orDash(row.categories), orDash(strings.Join(details, " ")))
+		if stale {
+			staleColumn := "-"
+			if row.stale {
+				staleColumn = "yes"
+			}
+			columns = append(columns, staleColumn)
+		}
+		fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// WriteCSV writes the results as CSV with one row per matching prefix.
+// Every key found in the prefix details becomes a column, the overlap column is only
+// present when any of the results comes from a network or range query, and the stale
+// column when any of the sources has stale data.
+func WriteCSV(w io.Writer, results []Result) error {
+	rows := flattenResults(results)
+	overlap := hasOverlap(rows)
+	stale := hasStale(rows)
+
+	detailKeys := make(map[string]string)
+	for _, row := range rows {
+		for k := range row.details {
+			detailKeys[k] = k
+		}
+	}
+	keys := sortedKeys(detailKeys)
+
+	cw := csv.NewWriter(w)
+	header := []string{"ip", "source", "network"}
+	if overlap {
+		header = append(header, "overlap")
+	}
+	header = append(header, "categories")
+	header = append(header, keys...)
+	if stale {
+		header = append(header, "stale")
+	}
+	header = append(header, "error")
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("failed to write csv: %v", err)
+	}
+
+	for _, row := range rows {
+		record := []string{row.ip, row.name, row.network}
+		if overlap {
+			record = append(record, string(row.overlap))
+		}
+		record = append(record, row.categories)
+		for _, k := range keys {
+			record = append(record, row.details[k])
+		}
+		if stale {
+			record = append(record, strconv.FormatBool(row.stale))
+		}
+		record = append(record, row.err)
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write csv: %v", err)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteYAML writes the results as a YAML sequence.
+func WriteYAML(w io.Writer, results []Result) error {
+	if results == nil {
+		results = []Result{}
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(results); err != nil {
+		return fmt.Errorf("failed to write yaml: %v", err)
+	}
+	return enc.Close()
+}
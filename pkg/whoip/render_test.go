@@ -0,0 +1,122 @@
+package whoip
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aorith/whoip/pkg/sources"
+	"gopkg.in/yaml.v3"
+)
+
+var testResults = []Result{
+	{
+		IP: "3.5.140.10",
+		Matches: []WhoIPInfo{
+			{
+				URL:         "https://www.example.com/ranges.json",
+				Name:        "Fake Cloud",
+				Description: "A fake source",
+				Categories:  []sources.Category{sources.Categories["datacenter"]},
+				Prefixes: []Prefix{
+					{Network: "3.5.140.0/22", Details: map[string]string{"Service": "CLOUDFRONT", "Region": "us-east-1"}},
+					{Network: "3.0.0.0/8", Details: map[string]string{"Service": "AMAZON"}},
+				},
+			},
+		},
+	},
+	{IP: "bad", Matches: []WhoIPInfo{}, Error: "invalid IP address: bad"},
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b strings.Builder
+	if err := WriteCSV(&b, testResults); err != nil {
+		t.Fatalf("Failed to write csv: %v", err)
+	}
+
+	expected := `ip,source,network,categories,Region,Service,error
+3.5.140.10,Fake Cloud,3.5.140.0/22,datacenter,us-east-1,CLOUDFRONT,
+3.5.140.10,Fake Cloud,3.0.0.0/8,datacenter,,AMAZON,
+bad,,,,,,invalid IP address: bad
+`
+	if b.String() != expected {
+		t.Errorf("Wrong csv output. Expected output:\n%s\n\nGot:\n%s\n", expected, b.String())
+	}
+}
+
+func TestWriteCSVStale(t *testing.T) {
+	results := []Result{{
+		IP: "3.5.140.10",
+		Matches: []WhoIPInfo{
+			{Name: "Fake Cloud", Prefixes: []Prefix{{Network: "3.0.0.0/8"}}, Stale: true},
+			{Name: "Fake Bot", Prefixes: []Prefix{{Network: "3.5.140.0/24"}}},
+		},
+	}}
+
+	var b strings.Builder
+	if err := WriteCSV(&b, results); err != nil {
+		t.Fatalf("Failed to write csv: %v", err)
+	}
+
+	expected := `ip,source,network,categories,stale,error
+3.5.140.10,Fake Cloud,3.0.0.0/8,,true,
+3.5.140.10,Fake Bot,3.5.140.0/24,,false,
+`
+	if b.String() != expected {
+		t.Errorf("Wrong csv output. Expected output:\n%s\n\nGot:\n%s\n", expected, b.String())
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	var b strings.Builder
+	if err := WriteYAML(&b, testResults); err != nil {
+		t.Fatalf("Failed to write yaml: %v", err)
+	}
+
+	expected := `- ip: 3.5.140.10
+  matches:
+    - url: https://www.example.com/ranges.json
+      name: Fake Cloud
+      description: A fake source
+      categories:
+        - id: datacenter
+          description: IP ranges belonging to data centers and hosting providers
+      prefixes:
+        - network: 3.5.140.0/22
+          details:
+            Region: us-east-1
+            Service: CLOUDFRONT
+        - network: 3.0.0.0/8
+          details:
+            Service: AMAZON
+- ip: bad
+  matches: []
+  error: 'invalid IP address: bad'
+`
+	if b.String() != expected {
+		t.Errorf("Wrong yaml output. Expected output:\n%s\n\nGot:\n%s\n", expected, b.String())
+	}
+}
+
+func TestWriteYAMLStrings(t *testing.T) {
+	var results []Result
+	for _, s := range []string{"2001:db8::1", "::/0", "true", "10", "1:20", "a: b", "", "2024-06-20", "0x1F", "0o17", "~"} {
+		results = append(results, Result{
+			IP:      s,
+			Matches: []WhoIPInfo{{Name: s, Categories: []sources.Category{}, Prefixes: []Prefix{{Network: "192.0.2.0/24", Details: map[string]string{s: s}}}}},
+		})
+	}
+
+	var b strings.Builder
+	if err := WriteYAML(&b, results); err != nil {
+		t.Fatalf("Failed to write yaml: %v", err)
+	}
+
+	var decoded []Result
+	if err := yaml.Unmarshal([]byte(b.String()), &decoded); err != nil {
+		t.Fatalf("Failed to decode yaml: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, results) {
+		t.Errorf("Wrong decoded yaml. Expected: %v, Got: %v\n%s", results, decoded, b.String())
+	}
+}
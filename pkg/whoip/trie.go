@@ -0,0 +1,140 @@
+package whoip
+
+import (
+	"net/netip"
+)
+
+// trie is a path compressed binary (Patricia) trie of prefixes of a single address family.
+// Every node stores the values of its prefix, lookups walk at most one node per
+// address bit.
+type trie[T any] struct {
+	root *trieNode[T]
+}
+
+type trieNode[T any] struct {
+	prefix   netip.Prefix
+	values   []T
+	children [2]*trieNode[T]
+}
+
+// newTrie returns an empty trie rooted at the given prefix, which must be
+// 0.0.0.0/0 or ::/0.
+func newTrie[T any](root netip.Prefix) *trie[T] {
+	return &trie[T]{root: &trieNode[T]{prefix: root}}
+}
+
+// insert adds the value to the given prefix, which must be masked and of
+// the same address family as the trie.
+func (t *trie[T]) insert(prefix netip.Prefix, value T) {
+	n := t.root
+	for {
+		if n.prefix == prefix {
+			n.values = append(n.values, value)
+			return
+		}
+
+		b := bitAt(prefix.Addr(), n.prefix.Bits())
+		child := n.children[b]
+		if child == nil {
+			n.children[b] = &trieNode[T]{prefix: prefix, values: []T{value}}
+			return
+		}
+
+		if child.prefix.Bits() <= prefix.Bits() && child.prefix.Contains(prefix.Addr()) {
+			n = child
+			continue
+		}
+
+		common := commonPrefix(child.prefix, prefix)
+		if common == prefix {
+			// The new prefix contains the child.
+			newNode := &trieNode[T]{prefix: prefix, values: []T{value}}
+			newNode.children[bitAt(child.prefix.Addr(), prefix.Bits())] = child
+			n.children[b] = newNode
+			return
+		}
+
+		split := &trieNode[T]{prefix: common}
+		split.children[bitAt(child.prefix.Addr(), common.Bits())] = child
+		split.children[bitAt(prefix.Addr(), common.Bits())] = &trieNode[T]{prefix: prefix, values: []T{value}}
+		n.children[b] = split
+		return
+	}
+}
+
+// lookup returns the values of every prefix that contains the address,
+// from the most to the least specific prefix.
+func (t *trie[T]) lookup(addr netip.Addr) []T {
+	var nodes []*trieNode[T]
+	for n := t.root; n != nil && n.prefix.Contains(addr); {
+		if len(n.values) > 0 {
+			nodes = append(nodes, n)
+		}
+		if n.prefix.Bits() == addr.BitLen() {
+			break
+		}
+		n = n.children[bitAt(addr, n.prefix.Bits())]
+	}
+
+	var values []T
+	for i := len(nodes) - 1; i >= 0; i-- {
+		values = append(values, nodes[i].values...)
+	}
+	return values
+}
+
+// overlapping returns the values of every prefix that overlaps the given prefix,
+// which must be masked. Prefixes that contain it come first, from the least to the
+// most specific, followed by the prefixes it contains in address order.
+func (t *trie[T]) overlapping(prefix netip.Prefix) []T {
+	var values []T
+	for n := t.root; n != nil; {
+		if n.prefix.Bits() <= prefix.Bits() && n.prefix.Contains(prefix.Addr()) {
+			values = append(values, n.values...)
+			if n.prefix == prefix {
+				for _, child := range n.children {
+					values = child.appendAll(values)
+				}
+				break
+			}
+			n = n.children[bitAt(prefix.Addr(), n.prefix.Bits())]
+			continue
+		}
+		if prefix.Contains(n.prefix.Addr()) {
+			values = n.appendAll(values)
+		}
+		break
+	}
+	return values
+}
+
+// appendAll appends the values of the node and all its descendants in address order.
+func (n *trieNode[T]) appendAll(values []T) []T {
+	if n == nil {
+		return values
+	}
+	values = append(values, n.values...)
+	values = n.children[0].appendAll(values)
+	return n.children[1].appendAll(values)
+}
+
+// bitAt returns the bit of the address at the given position, starting from
+// the most significant bit.
+func bitAt(addr netip.Addr, pos int) int {
+	if addr.Is4() {
+		pos += 96
+	}
+	b := addr.As16()
+	return int(b[pos/8]>>(7-pos%8)) & 1
+}
+
+// commonPrefix returns the longest prefix that contains both prefixes.
+func commonPrefix(a, b netip.Prefix) netip.Prefix {
+	bits := min(a.Bits(), b.Bits())
+	common := 0
+	for common < bits && bitAt(a.Addr(), common) == bitAt(b.Addr(), common) {
+		common++
+	}
+	prefix, _ := a.Addr().Prefix(common)
+	return prefix
+}
@@ -0,0 +1,86 @@
+package whoip
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTrieLookupOrder(t *testing.T) {
+	tr := newTrie[string](netip.MustParsePrefix("0.0.0.0/0"))
+	for _, p := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32", "10.2.0.0/16", "0.0.0.0/0"} {
+		tr.insert(netip.MustParsePrefix(p), p)
+	}
+
+	got := tr.lookup(netip.MustParseAddr("10.1.2.3"))
+	expected := []string{"10.1.2.3/32", "10.1.2.0/24", "10.1.0.0/16", "10.0.0.0/8", "0.0.0.0/0"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong lookup result. Expected output:\n%v\n\nGot:\n%v\n", expected, got)
+	}
+
+	got = tr.lookup(netip.MustParseAddr("10.2.200.1"))
+	expected = []string{"10.2.0.0/16", "10.0.0.0/8", "0.0.0.0/0"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong lookup result. Expected output:\n%v\n\nGot:\n%v\n", expected, got)
+	}
+}
+
+func TestTrieLookupMatchesLinearScan(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	randomAddr := func(is4 bool) netip.Addr {
+		if is4 {
+			// Keep addresses close together so prefixes overlap often.
+			return netip.AddrFrom4([4]byte{10, byte(r.IntN(4)), byte(r.IntN(256)), byte(r.IntN(256))})
+		}
+		var b [16]byte
+		b[0], b[1], b[2], b[3] = 0x20, 0x01, byte(r.IntN(4)), byte(r.IntN(256))
+		for i := 4; i < 16; i++ {
+			b[i] = byte(r.IntN(256))
+		}
+		return netip.AddrFrom16(b)
+	}
+
+	for _, is4 := range []bool{true, false} {
+		root := netip.MustParsePrefix("::/0")
+		if is4 {
+			root = netip.MustParsePrefix("0.0.0.0/0")
+		}
+		tr := newTrie[netip.Prefix](root)
+
+		var prefixes []netip.Prefix
+		for i := 0; i < 2000; i++ {
+			addr := randomAddr(is4)
+			bits := 8 + r.IntN(addr.BitLen()-7)
+			prefix, _ := addr.Prefix(bits)
+			prefixes = append(prefixes, prefix)
+			tr.insert(prefix, prefix)
+		}
+
+		for i := 0; i < 2000; i++ {
+			addr := randomAddr(is4)
+
+			var expected []netip.Prefix
+			for _, p := range prefixes {
+				if p.Contains(addr) {
+					expected = append(expected, p)
+				}
+			}
+			sort.SliceStable(expected, func(i, j int) bool {
+				return expected[i].Bits() > expected[j].Bits()
+			})
+
+			got := tr.lookup(addr)
+			if len(got) != len(expected) {
+				t.Fatalf("Wrong number of matches for '%s'. Expected: %v, Got: %v", addr, expected, got)
+			}
+			for i := range got {
+				if got[i].Bits() != expected[i].Bits() || !got[i].Contains(addr) {
+					t.Fatalf("Wrong matches for '%s'. Expected: %v, Got: %v", addr, expected, got)
+				}
+			}
+		}
+	}
+}
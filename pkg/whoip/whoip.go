@@ -1,86 +1,248 @@
 package whoip
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net"
+	"net/netip"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/aorith/whoip/pkg/sources"
 )
 
 type WhoIPInfo struct {
-	URL         string             `json:"url"`
-	Name        string             `json:"name"`
-	Description string             `json:"description"`
-	Categories  []sources.Category `json:"categories"`
-	Prefix      Prefix             `json:"prefix"`
+	URL         string             `json:"url" yaml:"url"`
+	Name        string             `json:"name" yaml:"name"`
+	Description string             `json:"description" yaml:"description"`
+	Categories  []sources.Category `json:"categories" yaml:"categories"`
+	Prefixes    []Prefix           `json:"prefixes" yaml:"prefixes"` // Ordered from the most to the least specific.
+	LastUpdate  time.Time          `json:"last_update" yaml:"last_update,omitempty"`
+	Published   *time.Time         `json:"published,omitempty" yaml:"published,omitempty"` // Publication time of the data by the provider.
+	Stale       bool               `json:"stale,omitempty" yaml:"stale,omitempty"`         // The data expired and could not be refreshed.
+	Error       string             `json:"error,omitempty" yaml:"error,omitempty"`         // Error of the last refresh of the source.
 }
 
 type Prefix struct {
-	Network string            `json:"network"`
-	Details map[string]string `json:"details"`
+	Network    string             `json:"network" yaml:"network"`
+	Details    map[string]string  `json:"details" yaml:"details"`
+	Categories []sources.Category `json:"categories,omitempty" yaml:"categories,omitempty"` // Overrides the source categories.
+	Overlap    Overlap            `json:"overlap,omitempty" yaml:"overlap,omitempty"`       // Only set on network and range queries.
 }
 
-func FindIP(ip net.IP) string {
-	UpdateSources()
-
-	var info []WhoIPInfo
-	for _, src := range sources.IPRangeSources {
-		prefix := src.ContainsIP(ip)
-		if prefix != nil {
-			newInfo := WhoIPInfo{
-				URL:         src.URL,
-				Name:        src.Name,
-				Description: src.Description,
-				Prefix:      Prefix{Network: prefix.Network.String(), Details: prefix.Details},
-			}
-			if len(prefix.Categories) > 0 {
-				newInfo.Categories = prefix.Categories
-			} else {
-				newInfo.Categories = src.Categories
-			}
-			info = append(info, newInfo)
-		}
+// Result holds the matches of a single query.
+// IP holds the queried address, network or range as given.
+type Result struct {
+	IP      string      `json:"ip" yaml:"ip"`
+	Matches []WhoIPInfo `json:"matches" yaml:"matches"`
+	Error   string      `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Lookup updates the sources selected by the filter and returns the information
+// of every selected source that contains the given IP address.
+// Sources that fail to update are looked up with the data they already have,
+// an error is only returned for invalid addresses or when the context is done.
+func (c *Client) Lookup(ctx context.Context, addr netip.Addr, filter Filter) ([]WhoIPInfo, error) {
+	if !addr.IsValid() {
+		return nil, fmt.Errorf("invalid IP address: %s", addr)
 	}
 
-	jsonData, err := json.MarshalIndent(info, "", "  ")
+	if err := c.UpdateSources(ctx, filter); err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return c.Match(addr, filter), nil
+}
+
+// Match returns the information of every source selected by the filter that contains
+// the given IP address, including all the matching prefixes of each source.
+// Sources are ordered from the most to the least specific match.
+// Unlike Lookup, it does not update the sources and only uses their current snapshot,
+// so it never blocks on a refresh.
+func (c *Client) Match(addr netip.Addr, filter Filter) []WhoIPInfo {
+	return matchIndex(c.index(), addr, filter)
+}
+
+// MatchString parses the query and returns its matches as a Result.
+// The query can be an IP address, a CIDR or an 'a-b' address range, see ParseRange.
+// Invalid queries are reported in the Error field of the Result.
+// Like Match, it only uses the current snapshot of the sources.
+func (c *Client) MatchString(query string, filter Filter) Result {
+	if addr, err := netip.ParseAddr(query); err == nil {
+		return Result{IP: query, Matches: c.Match(addr, filter)}
+	}
+	if !strings.ContainsAny(query, "/-") {
+		return Result{IP: query, Matches: []WhoIPInfo{}, Error: fmt.Sprintf("invalid IP address: %s", query)}
+	}
+
+	r, err := ParseRange(query)
 	if err != nil {
-		log.Fatalf("Error marshaling JSON: %v", err)
+		return Result{IP: query, Matches: []WhoIPInfo{}, Error: err.Error()}
+	}
+	return Result{IP: query, Matches: c.MatchRange(r, filter)}
+}
+
+// matchIndex groups the prefixes of the index that contain the address by source.
+func matchIndex(idx *index, addr netip.Addr, filter Filter) []WhoIPInfo {
+	return groupEntries(idx.lookup(addr), filter, nil)
+}
+
+// groupEntries groups the entries selected by the filter by source, keeping their order.
+// If overlap is not nil, it is used to set the Overlap of every prefix.
+func groupEntries(entries []indexEntry, filter Filter, overlap func(indexEntry) Overlap) []WhoIPInfo {
+	info := []WhoIPInfo{}
+	positions := make(map[string]int)
+	for _, entry := range entries {
+		if !filter.matchEntry(entry) {
+			continue
+		}
+		src, prefix := entry.source, entry.prefix
+		newPrefix := Prefix{
+			Network:    prefix.Network.String(),
+			Details:    prefix.Details,
+			Categories: prefix.Categories,
+		}
+		if overlap != nil {
+			newPrefix.Overlap = overlap(entry)
+		}
+
+		if i, ok := positions[entry.key]; ok {
+			info[i].Prefixes = append(info[i].Prefixes, newPrefix)
+			continue
+		}
+		positions[entry.key] = len(info)
+
+		status := src.Status()
+		newInfo := WhoIPInfo{
+			URL:         src.URL,
+			Name:        src.Name,
+			Description: src.Description,
+			Prefixes:    []Prefix{newPrefix},
+			LastUpdate:  status.LastUpdate,
+			Stale:       status.Stale,
+		}
+		if !status.Published.IsZero() {
+			newInfo.Published = &status.Published
+		}
+		if status.Error != nil {
+			newInfo.Error = status.Error.Error()
+		}
+		if len(prefix.Categories) > 0 {
+			newInfo.Categories = prefix.Categories
+		} else {
+			newInfo.Categories = src.Categories
+		}
+		info = append(info, newInfo)
 	}
 
-	return fmt.Sprintf("%s\n", jsonData)
+	return info
 }
 
-func UpdateSources() {
-	var wg sync.WaitGroup
-	numSources := len(sources.IPRangeSources)
-	wg.Add(numSources)
+// UpdateSources refreshes every source selected by the filter concurrently and
+// waits until all of them are done or the context is done.
+// The returned error joins the errors of all the sources that failed to update.
+func (c *Client) UpdateSources(ctx context.Context, filter Filter) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	selected := filter.SelectSources(c.Sources())
+	wg.Add(len(selected))
 
-	for _, src := range sources.IPRangeSources {
+	for _, src := range selected {
 		go func(src *sources.IPSource) {
 			defer wg.Done()
-			err := src.Fetcher(src)
+			err := src.Refresh(ctx)
 			if err != nil {
-				log.Printf("Failure updating source '%s'.", src.Name)
+				log.Printf("Failure updating source '%s': %v", src.Name, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("source '%s': %w", src.Name, err))
+				mu.Unlock()
 			}
 		}(src)
 	}
 
-	wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return errors.Join(errs...)
 }
 
-func Categories() string {
+// Categories returns all the available categories sorted by ID.
+func Categories() []sources.Category {
 	var categories []sources.Category
 	for _, cat := range sources.Categories {
 		categories = append(categories, cat)
 	}
 
-	jsonData, err := json.MarshalIndent(categories, "", "  ")
-	if err != nil {
-		log.Fatalf("Error marshaling JSON: %v", err)
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].ID < categories[j].ID
+	})
+
+	return categories
+}
+
+// Entry is a prefix of a source with its effective categories.
+type Entry struct {
+	Source     string // Key of the source.
+	Name       string // Name of the source.
+	Prefix     netip.Prefix
+	Categories []sources.Category
+	Details    map[string]string
+}
+
+// Entries returns every prefix of the sources selected by the filter, ordered by
+// source key and keeping the order of each source.
+// Like Match, it only uses the current snapshot of the sources.
+func (c *Client) Entries(filter Filter) []Entry {
+	selected := filter.SelectSources(c.Sources())
+	keys := make([]string, 0, len(selected))
+	for key := range selected {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	return fmt.Sprintf("%s\n", jsonData)
+	var entries []Entry
+	for _, key := range keys {
+		src := selected[key]
+		data := src.Snapshot()
+		if data == nil {
+			continue
+		}
+		for i := range data.Prefixes {
+			prefix := &data.Prefixes[i]
+			if !filter.matchEntry(indexEntry{key: key, source: src, prefix: prefix}) {
+				continue
+			}
+			network, ok := prefix.NetipPrefix()
+			if !ok {
+				continue
+			}
+			categories := src.Categories
+			if len(prefix.Categories) > 0 {
+				categories = prefix.Categories
+			}
+			entries = append(entries, Entry{
+				Source:     key,
+				Name:       src.Name,
+				Prefix:     network.Masked(),
+				Categories: categories,
+				Details:    prefix.Details,
+			})
+		}
+	}
+	return entries
 }
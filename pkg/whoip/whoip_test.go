@@ -0,0 +1,231 @@
+package whoip
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aorith/whoip/pkg/sources"
+)
+
+// fakeSource is a Source that serves the given prefixes.
+type fakeSource map[string]string
+
+func (fakeSource) Fetch(ctx context.Context, url string, cached sources.Validators) (io.ReadCloser, sources.Validators, error) {
+	return io.NopCloser(strings.NewReader("")), sources.Validators{}, nil
+}
+
+func (f fakeSource) Parse(r io.Reader) (*sources.Data, error) {
+	var prefixes []sources.Prefix
+	for cidr, service := range f {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, sources.Prefix{
+			Network: *network,
+			Details: map[string]string{"Service": service},
+		})
+	}
+	return &sources.Data{Prefixes: prefixes}, nil
+}
+
+// newFakeSource returns a refreshed source that serves the given prefixes.
+func newFakeSource(t *testing.T, name string, prefixes map[string]string) *sources.IPSource {
+	t.Helper()
+
+	src := &sources.IPSource{
+		Name:            name,
+		Categories:      []sources.Category{sources.Categories["datacenter"]},
+		RefreshInterval: 1 * time.Minute,
+		Source:          fakeSource(prefixes),
+	}
+	if err := src.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh fake source '%s': %v", name, err)
+	}
+	return src
+}
+
+func TestMatchAllPrefixesOfSource(t *testing.T) {
+	srcs := map[string]*sources.IPSource{
+		"cloud": newFakeSource(t, "Fake Cloud", map[string]string{
+			"3.0.0.0/8":      "AMAZON",
+			"3.5.0.0/16":     "EC2",
+			"3.5.140.0/22":   "CLOUDFRONT",
+			"3.6.0.0/16":     "S3",
+			"2600:1f00::/24": "AMAZON",
+		}),
+		"bot": newFakeSource(t, "Fake Bot", map[string]string{
+			"3.5.140.0/24": "BOT",
+		}),
+	}
+	idx := buildIndex(srcs)
+
+	info := matchIndex(idx, netip.MustParseAddr("3.5.140.10"), Filter{})
+	if len(info) != 2 {
+		t.Fatalf("Expected matches of 2 sources, got %d: %v", len(info), info)
+	}
+
+	if info[0].Name != "Fake Bot" {
+		t.Errorf("Expected the most specific source first, got '%s'", info[0].Name)
+	}
+
+	expected := []string{"CLOUDFRONT", "EC2", "AMAZON"}
+	cloud := info[1]
+	if len(cloud.Prefixes) != len(expected) {
+		t.Fatalf("Wrong number of prefixes. Expected: %d, Got: %v", len(expected), cloud.Prefixes)
+	}
+	for i, service := range expected {
+		if cloud.Prefixes[i].Details["Service"] != service {
+			t.Errorf("Wrong prefix at position %d. Expected: %s, Got: %s", i, service, cloud.Prefixes[i].Details["Service"])
+		}
+	}
+
+	info = matchIndex(idx, netip.MustParseAddr("2600:1f00::1"), Filter{})
+	if len(info) != 1 || info[0].Prefixes[0].Network != "2600:1f00::/24" {
+		t.Errorf("Wrong IPv6 match: %v", info)
+	}
+
+	if info := matchIndex(idx, netip.MustParseAddr("192.0.2.1"), Filter{}); len(info) != 0 {
+		t.Errorf("Expected no matches, got: %v", info)
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	bot := newFakeSource(t, "Fake Bot", map[string]string{"3.5.140.0/24": "BOT"})
+	bot.Categories = []sources.Category{sources.Categories["crawler"]}
+	srcs := map[string]*sources.IPSource{
+		"cloud": newFakeSource(t, "Fake Cloud", map[string]string{"3.0.0.0/8": "AMAZON"}),
+		"bot":   bot,
+	}
+	idx := buildIndex(srcs)
+	addr := netip.MustParseAddr("3.5.140.10")
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"no filter", Filter{}, []string{"Fake Bot", "Fake Cloud"}},
+		{"source", Filter{Sources: []string{"cloud"}}, []string{"Fake Cloud"}},
+		{"exclude source", Filter{ExcludeSources: []string{"cloud"}}, []string{"Fake Bot"}},
+		{"category", Filter{Categories: []string{"crawler", "cdn"}}, []string{"Fake Bot"}},
+		{"exclude category", Filter{ExcludeCategories: []string{"crawler"}}, []string{"Fake Cloud"}},
+		{"source and category", Filter{Sources: []string{"cloud"}, Categories: []string{"crawler"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, info := range matchIndex(idx, addr, tt.filter) {
+				names = append(names, info.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Wrong matches. Expected: %v, Got: %v", tt.expected, names)
+			}
+
+			var selected []string
+			for key := range tt.filter.SelectSources(srcs) {
+				selected = append(selected, srcs[key].Name)
+			}
+			sort.Strings(selected)
+			if !reflect.DeepEqual(selected, tt.expected) {
+				t.Errorf("Wrong selected sources. Expected: %v, Got: %v", tt.expected, selected)
+			}
+		})
+	}
+}
+
+func TestSelectSearchCrawlers(t *testing.T) {
+	filter := Filter{Categories: []string{"crawler"}, ExcludeCategories: []string{"ai"}}
+
+	var selected []string
+	for key := range filter.SelectSources(sources.Registered()) {
+		selected = append(selected, key)
+	}
+	sort.Strings(selected)
+
+	// Search engine crawlers are kept, AI crawlers and sources without crawlers are not.
+	for _, key := range []string{"google-bot", "bingbot", "applebot", "duckduckbot"} {
+		if !slices.Contains(selected, key) {
+			t.Errorf("Search crawler '%s' not selected: %v", key, selected)
+		}
+	}
+	for _, key := range []string{"openai-gptbot", "openai-chatgpt-user", "perplexitybot", "ccbot", "aws", "azure", "github"} {
+		if slices.Contains(selected, key) {
+			t.Errorf("Source '%s' selected: %v", key, selected)
+		}
+	}
+}
+
+func TestSelectSourcesByPrefixCategories(t *testing.T) {
+	src := &sources.IPSource{
+		Categories:       []sources.Category{sources.Categories["datacenter"]},
+		PrefixCategories: []sources.Category{sources.Categories["crawler"]},
+	}
+
+	tests := map[string]struct {
+		filter   Filter
+		expected bool
+	}{
+		"prefix category":           {Filter{Categories: []string{"crawler"}}, true},
+		"default category excluded": {Filter{ExcludeCategories: []string{"datacenter"}}, true},
+		"all categories excluded":   {Filter{ExcludeCategories: []string{"datacenter", "crawler"}}, false},
+		"other category":            {Filter{Categories: []string{"cdn"}}, false},
+	}
+	for name, tt := range tests {
+		if got := tt.filter.MatchSource("azure", src); got != tt.expected {
+			t.Errorf("Wrong selection for the filter with %s. Expected: %t, Got: %t", name, tt.expected, got)
+		}
+	}
+}
+
+func TestClientUsesItsOwnSources(t *testing.T) {
+	office := newFakeSource(t, "Office", map[string]string{"192.0.2.0/24": "VPN"})
+	client := NewClient(map[string]*sources.IPSource{"office": office})
+
+	if err := client.UpdateSources(context.Background(), Filter{}); err != nil {
+		t.Fatalf("Failed to update sources: %v", err)
+	}
+
+	info := client.Match(netip.MustParseAddr("192.0.2.10"), Filter{})
+	if len(info) != 1 || info[0].Name != "Office" {
+		t.Errorf("Wrong matches. Expected: [Office], Got: %v", info)
+	}
+
+	if err := client.ValidateFilter(Filter{Sources: []string{"office"}}); err != nil {
+		t.Errorf("Unexpected error validating the filter: %v", err)
+	}
+	if err := client.ValidateFilter(Filter{Sources: []string{"aws"}}); err == nil {
+		t.Errorf("Expected an error validating a source that is not in the client")
+	}
+
+	if entries := client.Entries(Filter{}); len(entries) != 1 || entries[0].Source != "office" {
+		t.Errorf("Wrong entries: %v", entries)
+	}
+}
+
+func TestLookupReusesIndex(t *testing.T) {
+	office := newFakeSource(t, "Office", map[string]string{"192.0.2.0/24": "VPN"})
+	client := NewClient(map[string]*sources.IPSource{"office": office})
+
+	addr := netip.MustParseAddr("192.0.2.10")
+	if _, err := client.Lookup(context.Background(), addr, Filter{}); err != nil {
+		t.Fatalf("Failed to lookup: %v", err)
+	}
+	idx := client.index()
+
+	if _, err := client.Lookup(context.Background(), addr, Filter{}); err != nil {
+		t.Fatalf("Failed to lookup: %v", err)
+	}
+	if client.index() != idx {
+		t.Errorf("Index rebuilt while the data of the sources did not change")
+	}
+}